@@ -21,4 +21,4 @@ async def categorize(req: CategorizeRequest):
     print(f"[INFO] Received description: {req.description}")
     result = classifier(req.description, categories)
     print(f"[INFO] Predicted category: {result['labels'][0]}")
-    return {"category": result['labels'][0]}
\ No newline at end of file
+    return {"category": result['labels'][0], "confidence": result['scores'][0]}
\ No newline at end of file
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// accountTypes are the kinds of account money can be held in
+var accountTypes = map[string]bool{"cash": true, "bank": true, "credit_card": true, "upi": true, "wallet": true}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Account is somewhere the user holds money. Its balance starts at OpeningBalance on
+// OpeningDate and moves with the payments, expenses, income and transfers recorded against it.
+// A credit card with StatementDay and DueDay set groups its expenses into monthly statements.
+type Account struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Currency       string    `json:"currency"`
+	OpeningBalance float64   `json:"opening_balance"`
+	OpeningDate    time.Time `json:"opening_date"`
+	Archived       bool      `json:"archived"`
+	StatementDay   *int      `json:"statement_day"` // day of month the card's statement closes
+	DueDay         *int      `json:"due_day"`       // day of month the statement is due
+	Balance        float64   `json:"balance"`       // as of today, read-only
+}
+
+// MarshalJSON formats OpeningDate as YYYY-MM-DD
+func (a Account) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID             int     `json:"id"`
+		UserID         int     `json:"user_id"`
+		Name           string  `json:"name"`
+		Type           string  `json:"type"`
+		Currency       string  `json:"currency"`
+		OpeningBalance float64 `json:"opening_balance"`
+		OpeningDate    string  `json:"opening_date"`
+		Archived       bool    `json:"archived"`
+		StatementDay   *int    `json:"statement_day"`
+		DueDay         *int    `json:"due_day"`
+		Balance        float64 `json:"balance"`
+	}{a.ID, a.UserID, a.Name, a.Type, a.Currency, a.OpeningBalance, a.OpeningDate.Format("2006-01-02"), a.Archived, a.StatementDay, a.DueDay, a.Balance})
+}
+
+const accountColumns = "id, user_id, name, type, currency, opening_balance, opening_date, archived, statement_day, due_day"
+
+func scanAccount(row pgx.Row) (Account, error) {
+	var a Account
+	err := row.Scan(&a.ID, &a.UserID, &a.Name, &a.Type, &a.Currency, &a.OpeningBalance, &a.OpeningDate, &a.Archived, &a.StatementDay, &a.DueDay)
+	return a, err
+}
+
+// loadOwnedAccount loads an account owned by userID; an unknown account is a 404
+func loadOwnedAccount(ctx context.Context, q querier, id, userID int) (Account, error) {
+	a, err := scanAccount(q.QueryRow(ctx, "SELECT "+accountColumns+" FROM accounts WHERE id=$1 AND user_id=$2", id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, newAPIError(http.StatusNotFound, "Account not found")
+	}
+	return a, err
+}
+
+// checkAccount verifies that an optional account_id on a request belongs to userID
+func checkAccount(ctx context.Context, q querier, userID int, accountID *int) error {
+	if accountID == nil {
+		return nil
+	}
+	var exists bool
+	if err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM accounts WHERE id=$1 AND user_id=$2)", *accountID, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return newAPIError(http.StatusBadRequest, "Unknown account_id")
+	}
+	return nil
+}
+
+// Transfer moves money between two of the user's accounts. It is not spending. ToAmount is
+// what arrives, which differs from Amount only between accounts in different currencies.
+type Transfer struct {
+	ID            int       `json:"id"`
+	FromAccountID int       `json:"from_account_id"`
+	ToAccountID   int       `json:"to_account_id"`
+	Amount        float64   `json:"amount"`
+	ToAmount      float64   `json:"to_amount"`
+	Date          time.Time `json:"date"`
+	Note          string    `json:"note"`
+}
+
+// MarshalJSON formats Date as YYYY-MM-DD
+func (t Transfer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID            int     `json:"id"`
+		FromAccountID int     `json:"from_account_id"`
+		ToAccountID   int     `json:"to_account_id"`
+		Amount        float64 `json:"amount"`
+		ToAmount      float64 `json:"to_amount"`
+		Date          string  `json:"date"`
+		Note          string  `json:"note"`
+	}{t.ID, t.FromAccountID, t.ToAccountID, t.Amount, t.ToAmount, t.Date.Format("2006-01-02"), t.Note})
+}
+
+const transferColumns = "id, from_account_id, to_account_id, amount, to_amount, date, note"
+
+func scanTransfer(row pgx.Row) (Transfer, error) {
+	var t Transfer
+	err := row.Scan(&t.ID, &t.FromAccountID, &t.ToAccountID, &t.Amount, &t.ToAmount, &t.Date, &t.Note)
+	return t, err
+}
+
+// ledgerEntry is one movement on an account with the balance after it
+type ledgerEntry struct {
+	Date        string  `json:"date"`
+	Type        string  `json:"type"` // opening, income, payment, expense, refund, card_payment, transfer_in or transfer_out
+	RefID       int     `json:"ref_id"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"` // signed: money in is positive
+	Balance     float64 `json:"balance"`
+
+	date time.Time
+}
+
+// ledgerOrder sorts movements on the same day: opening balance first, money in before money out
+var ledgerOrder = map[string]int{"opening": 0, "income": 1, "transfer_in": 2, "refund": 3, "card_payment": 4, "transfer_out": 5, "payment": 6, "expense": 7}
+
+// accountLedger lists an account's movements up to today in date order with running balances.
+// Money leaves an account through its payments, and through expenses marked paid that have no
+// payment records; it arrives as income, refunds of its payments and transfers. Every expense
+// charged to a credit card is owed on it until statement payments settle the balance.
+func accountLedger(ctx context.Context, userID int, a Account) ([]ledgerEntry, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	entries := []ledgerEntry{{date: a.OpeningDate, Type: "opening", RefID: a.ID, Description: "Opening balance", Amount: a.OpeningBalance}}
+	expenseQuery := "SELECT e.id, e.date, e.amount, e.description FROM expenses e WHERE e.account_id=$1 AND e.user_id=$2 AND e.date <= $3 AND e.paid AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.expense_id=e.id)"
+	if a.Type == "credit_card" {
+		expenseQuery = "SELECT e.id, e.date, e.amount, e.description FROM expenses e WHERE e.account_id=$1 AND e.user_id=$2 AND e.date <= $3"
+	}
+
+	queries := []struct {
+		kind  string
+		sign  float64
+		query string
+	}{
+		{"payment", -1, "SELECT p.id, p.payment_date, p.amount, COALESCE(e.description, p.description, '') FROM payments p LEFT JOIN expenses e ON e.id=p.expense_id WHERE p.account_id=$1 AND p.user_id=$2 AND p.payment_date <= $3"},
+		{"expense", -1, expenseQuery},
+		{"refund", 1, "SELECT rf.id, rf.created_at::date, rf.amount, 'Refund: ' || rf.reason FROM refunds rf JOIN payments p ON p.id=rf.payment_id WHERE p.account_id=$1 AND rf.user_id=$2 AND rf.status<>'failed' AND rf.created_at::date <= $3"},
+		{"card_payment", 1, "SELECT p.id, p.payment_date, p.amount, COALESCE(p.description, 'Card bill payment') FROM payments p JOIN card_statements s ON s.id=p.statement_id WHERE s.account_id=$1 AND p.user_id=$2 AND p.payment_date <= $3"},
+		{"transfer_out", -1, "SELECT t.id, t.date, t.amount, t.note FROM transfers t WHERE t.from_account_id=$1 AND t.user_id=$2 AND t.date <= $3"},
+		{"transfer_in", 1, "SELECT t.id, t.date, t.to_amount, t.note FROM transfers t WHERE t.to_account_id=$1 AND t.user_id=$2 AND t.date <= $3"},
+	}
+	for _, lq := range queries {
+		rows, err := db.Query(ctx, lq.query, a.ID, userID, today)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			e := ledgerEntry{Type: lq.kind}
+			var amount float64
+			if err := rows.Scan(&e.RefID, &e.date, &amount, &e.Description); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			e.Amount = lq.sign * amount
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.Query(ctx, "SELECT "+incomeColumns+" FROM incomes WHERE account_id=$1 AND user_id=$2 AND date <= $3", a.ID, userID, today)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		in, err := scanIncome(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		description := in.Description
+		if description == "" {
+			description = in.Source
+		}
+		for _, d := range in.occurrences(in.Date, today) {
+			entries = append(entries, ledgerEntry{date: d, Type: "income", RefID: in.ID, Description: description, Amount: in.Amount})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].date.Equal(entries[j].date) {
+			return entries[i].date.Before(entries[j].date)
+		}
+		if ledgerOrder[entries[i].Type] != ledgerOrder[entries[j].Type] {
+			return ledgerOrder[entries[i].Type] < ledgerOrder[entries[j].Type]
+		}
+		return entries[i].RefID < entries[j].RefID
+	})
+	var balance float64
+	for i := range entries {
+		balance += entries[i].Amount
+		entries[i].Balance = roundMoney(balance)
+		entries[i].Amount = roundMoney(entries[i].Amount)
+		entries[i].Date = entries[i].date.Format("2006-01-02")
+	}
+	return entries, nil
+}
+
+// accountBalance is the balance of an account today
+func accountBalance(ctx context.Context, userID int, a Account) (float64, error) {
+	entries, err := accountLedger(ctx, userID, a)
+	if err != nil {
+		return 0, err
+	}
+	return entries[len(entries)-1].Balance, nil
+}
+
+// ledgerGrowthSince is how much a ledger's balance has changed since start. The opening
+// balance counts as already there, whatever its date.
+func ledgerGrowthSince(entries []ledgerEntry, start time.Time) float64 {
+	var growth float64
+	for _, e := range entries {
+		if e.Type != "opening" && !e.date.Before(start) {
+			growth += e.Amount
+		}
+	}
+	return roundMoney(growth)
+}
+
+// accountInput is the request body for creating or updating an account
+type accountInput struct {
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Currency       string  `json:"currency"`
+	OpeningBalance float64 `json:"opening_balance"`
+	OpeningDate    string  `json:"opening_date"`
+	Archived       bool    `json:"archived"`
+	StatementDay   *int    `json:"statement_day"`
+	DueDay         *int    `json:"due_day"`
+}
+
+// toAccount validates the input; currency defaults to INR and the opening date to today
+func (in accountInput) toAccount() (Account, error) {
+	a := Account{
+		Name: strings.TrimSpace(in.Name), Type: in.Type, Currency: strings.ToUpper(strings.TrimSpace(in.Currency)),
+		OpeningBalance: in.OpeningBalance, Archived: in.Archived, StatementDay: in.StatementDay, DueDay: in.DueDay,
+	}
+	if a.Name == "" {
+		return a, errors.New("name required")
+	}
+	if !accountTypes[a.Type] {
+		return a, errors.New("type must be one of cash, bank, credit_card, upi or wallet")
+	}
+	if a.Currency == "" {
+		a.Currency = "INR"
+	}
+	if !currencyPattern.MatchString(a.Currency) {
+		return a, errors.New("currency must be a three-letter ISO code")
+	}
+	now := time.Now()
+	a.OpeningDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if in.OpeningDate != "" {
+		t, err := time.Parse("2006-01-02", in.OpeningDate)
+		if err != nil {
+			return a, errors.New("Invalid opening_date format. Use YYYY-MM-DD.")
+		}
+		a.OpeningDate = t
+	}
+	if a.StatementDay != nil || a.DueDay != nil {
+		if a.Type != "credit_card" {
+			return a, errors.New("statement_day and due_day only apply to credit cards")
+		}
+		if a.StatementDay == nil || a.DueDay == nil {
+			return a, errors.New("statement_day and due_day must be set together")
+		}
+		if *a.StatementDay < 1 || *a.StatementDay > 31 || *a.DueDay < 1 || *a.DueDay > 31 {
+			return a, errors.New("statement_day and due_day must be between 1 and 31")
+		}
+	}
+	return a, nil
+}
+
+func registerAccountRoutes(auth *gin.RouterGroup) {
+	// Accounts with their current balances; archived accounts only with ?archived=true
+	auth.GET("/accounts", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		query := "SELECT " + accountColumns + " FROM accounts WHERE user_id=$1"
+		if c.Query("archived") != "true" {
+			query += " AND NOT archived"
+		}
+		rows, err := db.Query(ctx, query+" ORDER BY name", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		accounts := make([]Account, 0)
+		for rows.Next() {
+			if a, err := scanAccount(rows); err == nil {
+				accounts = append(accounts, a)
+			}
+		}
+		rows.Close()
+		for i := range accounts {
+			if accounts[i].Balance, err = accountBalance(ctx, userID, accounts[i]); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, accounts)
+	})
+
+	auth.POST("/accounts", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input accountInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		a, err := input.toAccount()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		a.UserID = userID
+		err = db.QueryRow(context.Background(),
+			"INSERT INTO accounts (user_id, name, type, currency, opening_balance, opening_date, archived, statement_day, due_day) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+			userID, a.Name, a.Type, a.Currency, a.OpeningBalance, a.OpeningDate, a.Archived, a.StatementDay, a.DueDay).Scan(&a.ID)
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add account"})
+			return
+		}
+		a.Balance = roundMoney(a.OpeningBalance)
+		c.JSON(http.StatusCreated, a)
+	})
+
+	// Update an account. The currency can't change once the account is used. Changing a card's
+	// statement cycle regroups its unpaid statements the next time they are listed.
+	auth.PUT("/accounts/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input accountInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		a, err := input.toAccount()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			existing, err := loadOwnedAccount(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			if input.OpeningDate == "" {
+				a.OpeningDate = existing.OpeningDate
+			}
+			if existing.Type == "credit_card" && a.Type != "credit_card" {
+				var billed bool
+				if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM card_statements WHERE account_id=$1 AND total > 0)", existing.ID).Scan(&billed); err != nil {
+					return err
+				}
+				if billed {
+					return newAPIError(http.StatusConflict, "Cannot change the type of a credit card that has statements")
+				}
+			}
+			if a.Currency != existing.Currency {
+				inUse, err := accountInUse(ctx, tx, existing.ID)
+				if err != nil {
+					return err
+				}
+				if inUse {
+					return newAPIError(http.StatusConflict, "Cannot change the currency of an account that has transactions")
+				}
+			}
+			a.ID, a.UserID = existing.ID, userID
+			_, err = tx.Exec(ctx,
+				"UPDATE accounts SET name=$1, type=$2, currency=$3, opening_balance=$4, opening_date=$5, archived=$6, statement_day=$7, due_day=$8 WHERE id=$9",
+				a.Name, a.Type, a.Currency, a.OpeningBalance, a.OpeningDate, a.Archived, a.StatementDay, a.DueDay, a.ID)
+			if isUniqueViolation(err) {
+				return newAPIError(http.StatusConflict, "Account already exists")
+			}
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update account")
+			return
+		}
+		if a.Balance, err = accountBalance(ctx, userID, a); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, a)
+	})
+
+	// Delete an unused account; accounts with transactions can be archived instead
+	auth.DELETE("/accounts/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			a, err := loadOwnedAccount(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			inUse, err := accountInUse(ctx, tx, a.ID)
+			if err != nil {
+				return err
+			}
+			if inUse {
+				return newAPIError(http.StatusConflict, "Account has transactions; archive it instead")
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM accounts WHERE id=$1", a.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to delete account")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+	})
+
+	// Movements on an account with running balances; ?from= and ?to= limit the entries shown
+	// without changing the balances
+	auth.GET("/accounts/:id/ledger", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		a, err := loadOwnedAccount(ctx, db, atoi(c.Param("id")), userID)
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		entries, err := accountLedger(ctx, userID, a)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		from, to := c.Query("from"), c.Query("to")
+		shown := make([]ledgerEntry, 0, len(entries))
+		for _, e := range entries {
+			if (from == "" || e.Date >= from) && (to == "" || e.Date <= to) {
+				shown = append(shown, e)
+			}
+		}
+		a.Balance = entries[len(entries)-1].Balance
+		c.JSON(http.StatusOK, gin.H{"account": a, "entries": shown})
+	})
+
+	// Transfers, newest first; ?account_id= lists those in or out of one account
+	auth.GET("/transfers", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		query := "SELECT " + transferColumns + " FROM transfers WHERE user_id=$1"
+		args := []any{userID}
+		if v := c.Query("account_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account_id"})
+				return
+			}
+			query += " AND (from_account_id=$2 OR to_account_id=$2)"
+			args = append(args, id)
+		}
+		rows, err := db.Query(context.Background(), query+" ORDER BY date DESC, id DESC", args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		transfers := make([]Transfer, 0)
+		for rows.Next() {
+			if t, err := scanTransfer(rows); err == nil {
+				transfers = append(transfers, t)
+			}
+		}
+		c.JSON(http.StatusOK, transfers)
+	})
+
+	// Move money between accounts. to_amount is required between accounts in different currencies.
+	auth.POST("/transfers", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			FromAccountID int     `json:"from_account_id"`
+			ToAccountID   int     `json:"to_account_id"`
+			Amount        float64 `json:"amount"`
+			ToAmount      float64 `json:"to_amount"`
+			Date          string  `json:"date"`
+			Note          string  `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Amount <= 0 || input.ToAmount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+			return
+		}
+		if input.FromAccountID == input.ToAccountID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer to the same account"})
+			return
+		}
+		t := Transfer{FromAccountID: input.FromAccountID, ToAccountID: input.ToAccountID, Amount: input.Amount, ToAmount: input.ToAmount, Note: input.Note, Date: time.Now()}
+		if input.Date != "" {
+			d, err := time.Parse("2006-01-02", input.Date)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+				return
+			}
+			t.Date = d
+		}
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			from, err := loadOwnedAccount(ctx, tx, t.FromAccountID, userID)
+			if err != nil {
+				return newAPIError(http.StatusBadRequest, "Unknown from_account_id")
+			}
+			to, err := loadOwnedAccount(ctx, tx, t.ToAccountID, userID)
+			if err != nil {
+				return newAPIError(http.StatusBadRequest, "Unknown to_account_id")
+			}
+			if from.Currency == to.Currency {
+				t.ToAmount = t.Amount
+			} else if t.ToAmount == 0 {
+				return newAPIError(http.StatusBadRequest, "to_amount required between accounts in different currencies")
+			}
+			return tx.QueryRow(ctx,
+				"INSERT INTO transfers (user_id, from_account_id, to_account_id, amount, to_amount, date, note) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+				userID, t.FromAccountID, t.ToAccountID, t.Amount, t.ToAmount, t.Date, t.Note).Scan(&t.ID)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add transfer")
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+	})
+
+	auth.DELETE("/transfers/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "DELETE FROM transfers WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transfer"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Transfer deleted"})
+	})
+}
+
+// accountInUse reports whether anything is recorded against an account
+func accountInUse(ctx context.Context, q querier, id int) (bool, error) {
+	var inUse bool
+	err := q.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM payments WHERE account_id=$1) OR EXISTS (SELECT 1 FROM expenses WHERE account_id=$1) "+
+			"OR EXISTS (SELECT 1 FROM incomes WHERE account_id=$1) OR EXISTS (SELECT 1 FROM transfers WHERE from_account_id=$1 OR to_account_id=$1) "+
+			"OR EXISTS (SELECT 1 FROM savings_goals WHERE account_id=$1) "+
+			"OR EXISTS (SELECT 1 FROM card_statements s JOIN payments p ON p.statement_id=s.id WHERE s.account_id=$1)",
+		id).Scan(&inUse)
+	return inUse, err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// categoryTotal is spending in one category over a period
+type categoryTotal struct {
+	Category string  `json:"category"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+// tagTotal is spending and payments carrying one tag over a period
+type tagTotal struct {
+	Tag          string  `json:"tag"`
+	ExpenseTotal float64 `json:"expense_total"`
+	ExpenseCount int     `json:"expense_count"`
+	PaymentTotal float64 `json:"payment_total"`
+	PaymentCount int     `json:"payment_count"`
+}
+
+// summaryPeriod reads ?from= and ?to= (YYYY-MM-DD, inclusive), defaulting to the current month
+func summaryPeriod(c *gin.Context) (from, to time.Time, err error) {
+	now := time.Now()
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to = from.AddDate(0, 1, -1)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func registerAnalyticsRoutes(auth *gin.RouterGroup) {
+	// Totals for a period: income, expenses and payments overall with net cash flow (income less
+	// expenses) and savings rate, expenses by category, and expenses and payments by tag
+	auth.GET("/analytics/summary", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		from, to, err := summaryPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+			return
+		}
+		ctx := context.Background()
+		end := to.AddDate(0, 0, 1) // queries take [from, end), like sumExpenses
+
+		var expenseTotal, paymentTotal float64
+		var expenseCount, paymentCount int
+		err = db.QueryRow(ctx,
+			"SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM expenses WHERE user_id=$1 AND date >= $2 AND date < $3",
+			userID, from, end).Scan(&expenseTotal, &expenseCount)
+		if err == nil {
+			err = db.QueryRow(ctx,
+				"SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM payments WHERE user_id=$1 AND payment_date >= $2 AND payment_date < $3",
+				userID, from, end).Scan(&paymentTotal, &paymentCount)
+		}
+		var incomeTotal float64
+		var incomeCount int
+		if err == nil {
+			incomeTotal, incomeCount, err = sumIncome(ctx, userID, from, to)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		netCashFlow, savingsRate := cashFlow(incomeTotal, expenseTotal)
+
+		byCategory := make([]categoryTotal, 0)
+		rows, err := db.Query(ctx,
+			"SELECT category, SUM(amount), COUNT(*) FROM expenses WHERE user_id=$1 AND date >= $2 AND date < $3 GROUP BY category ORDER BY SUM(amount) DESC",
+			userID, from, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		for rows.Next() {
+			var ct categoryTotal
+			if err := rows.Scan(&ct.Category, &ct.Total, &ct.Count); err == nil {
+				byCategory = append(byCategory, ct)
+			}
+		}
+		rows.Close()
+
+		byTag := make([]tagTotal, 0)
+		rows, err = db.Query(ctx,
+			"SELECT t.name, "+
+				"COALESCE((SELECT SUM(e.amount) FROM expense_tags et JOIN expenses e ON e.id=et.expense_id WHERE et.tag_id=t.id AND e.date >= $2 AND e.date < $3), 0), "+
+				"(SELECT COUNT(*) FROM expense_tags et JOIN expenses e ON e.id=et.expense_id WHERE et.tag_id=t.id AND e.date >= $2 AND e.date < $3), "+
+				"COALESCE((SELECT SUM(p.amount) FROM payment_tags pt JOIN payments p ON p.id=pt.payment_id WHERE pt.tag_id=t.id AND p.payment_date >= $2 AND p.payment_date < $3), 0), "+
+				"(SELECT COUNT(*) FROM payment_tags pt JOIN payments p ON p.id=pt.payment_id WHERE pt.tag_id=t.id AND p.payment_date >= $2 AND p.payment_date < $3) "+
+				"FROM tags t WHERE t.user_id=$1 ORDER BY t.name",
+			userID, from, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		for rows.Next() {
+			var tt tagTotal
+			if err := rows.Scan(&tt.Tag, &tt.ExpenseTotal, &tt.ExpenseCount, &tt.PaymentTotal, &tt.PaymentCount); err == nil && tt.ExpenseCount+tt.PaymentCount > 0 {
+				byTag = append(byTag, tt)
+			}
+		}
+		rows.Close()
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":          from.Format("2006-01-02"),
+			"to":            to.Format("2006-01-02"),
+			"expense_total": roundMoney(expenseTotal),
+			"expense_count": expenseCount,
+			"payment_total": roundMoney(paymentTotal),
+			"payment_count": paymentCount,
+			"income_total":  incomeTotal,
+			"income_count":  incomeCount,
+			"net_cash_flow": netCashFlow,
+			"savings_rate":  savingsRate,
+			"by_category":   byCategory,
+			"by_tag":        byTag,
+		})
+	})
+}
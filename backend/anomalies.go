@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Anomaly detection settings. Amounts are compared with the median of the same category over
+// the baseline window using the modified z-score (0.6745 * deviation / MAD); frequency compares
+// the last week's count with the weekly rate over the rest of the window.
+const (
+	anomalyWindowDays   = 90
+	anomalyMinSamples   = 5   // fewer past transactions than this is not a baseline
+	anomalyZThreshold   = 3.5 // modified z-score above which an amount is flagged
+	anomalyMinWeekCount = 3   // a week with fewer transactions is never a frequency spike
+)
+
+// Anomaly kinds
+const (
+	anomalyAmount    = "amount"
+	anomalyFrequency = "frequency"
+)
+
+// Anomaly is an expense or payment that stands out from the user's usual spending
+type Anomaly struct {
+	ID          int       `json:"id"`
+	SubjectType string    `json:"subject_type"` // expense or payment
+	SubjectID   int       `json:"subject_id"`
+	Kind        string    `json:"kind"`
+	Category    string    `json:"category"`
+	Amount      float64   `json:"amount"`
+	Baseline    float64   `json:"baseline"` // median amount, or usual transactions per week
+	Score       float64   `json:"score"`
+	Explanation string    `json:"explanation"`
+	Status      string    `json:"status"` // open or dismissed
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const anomalyColumns = "id, subject_type, subject_id, kind, category, amount, baseline, score, explanation, status, created_at"
+
+func scanAnomaly(row pgx.Row) (Anomaly, error) {
+	var a Anomaly
+	err := row.Scan(&a.ID, &a.SubjectType, &a.SubjectID, &a.Kind, &a.Category, &a.Amount, &a.Baseline, &a.Score, &a.Explanation, &a.Status, &a.CreatedAt)
+	return a, err
+}
+
+// anomalySubject is a new transaction to check
+type anomalySubject struct {
+	Type     string // expense or payment
+	ID       int
+	Category string
+	Amount   float64
+	Date     time.Time
+}
+
+// expenseSubject is the anomaly check for a saved expense
+func expenseSubject(exp Expense) anomalySubject {
+	return anomalySubject{Type: "expense", ID: exp.ID, Category: exp.Category, Amount: exp.Amount, Date: exp.Date}
+}
+
+// anomalyHistory selects (amount, date) of a user's other transactions in a category within a date range
+var anomalyHistory = map[string]string{
+	"expense": "SELECT amount, date FROM expenses WHERE user_id=$1 AND category=$2 AND id<>$3 AND date >= $4 AND date <= $5",
+	"payment": "SELECT p.amount, p.payment_date FROM payments p LEFT JOIN expenses e ON e.id=p.expense_id " +
+		"WHERE p.user_id=$1 AND COALESCE(e.category, p.category, '')=$2 AND p.id<>$3 AND p.payment_date >= $4 AND p.payment_date <= $5",
+}
+
+// median of a sorted slice
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// amountScore is the modified z-score of x against past amounts, with the median it is measured from
+func amountScore(x float64, past []float64) (score, med float64) {
+	sorted := append([]float64(nil), past...)
+	sort.Float64s(sorted)
+	med = median(sorted)
+	devs := make([]float64, len(sorted))
+	for i, v := range sorted {
+		devs[i] = math.Abs(v - med)
+	}
+	sort.Float64s(devs)
+	mad := median(devs)
+	if mad == 0 {
+		// Identical past amounts: fall back to the mean absolute deviation, and failing that
+		// treat anything over three times the usual amount as an outlier
+		var sum float64
+		for _, d := range devs {
+			sum += d
+		}
+		if meanDev := sum / float64(len(devs)); meanDev > 0 {
+			return (x - med) / (1.253314 * meanDev), med
+		}
+		if med > 0 && x > 3*med {
+			return anomalyZThreshold + 1, med
+		}
+		return 0, med
+	}
+	return 0.6745 * (x - med) / mad, med
+}
+
+// detectAnomalies checks a new transaction against the user's baseline for its category,
+// records any anomalies and notifies the user about them
+func detectAnomalies(ctx context.Context, q querier, userID int, s anomalySubject) ([]Anomaly, error) {
+	if s.Category == "" {
+		return nil, nil
+	}
+	day := time.Date(s.Date.Year(), s.Date.Month(), s.Date.Day(), 0, 0, 0, 0, time.UTC)
+	windowStart := day.AddDate(0, 0, -anomalyWindowDays)
+	rows, err := q.Query(ctx, anomalyHistory[s.Type], userID, s.Category, s.ID, windowStart, day)
+	if err != nil {
+		return nil, err
+	}
+	var amounts []float64
+	var dates []time.Time
+	for rows.Next() {
+		var amount float64
+		var date time.Time
+		if err := rows.Scan(&amount, &date); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		amounts = append(amounts, amount)
+		dates = append(dates, date)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(amounts) < anomalyMinSamples {
+		return nil, nil
+	}
+
+	var found []Anomaly
+	if score, med := amountScore(s.Amount, amounts); score > anomalyZThreshold {
+		found = append(found, Anomaly{
+			Kind: anomalyAmount, Baseline: roundMoney(med), Score: math.Round(score*100) / 100,
+			Explanation: fmt.Sprintf("₹%.2f is unusually high for %s: your typical %s transaction over the last %d days is ₹%.2f.",
+				s.Amount, s.Category, s.Category, anomalyWindowDays, med),
+		})
+	}
+
+	// This week includes the new transaction; the weekly rate comes from the weeks before it
+	weekStart := day.AddDate(0, 0, -6)
+	thisWeek := 1
+	earlier := 0
+	for _, d := range dates {
+		if !d.Before(weekStart) {
+			thisWeek++
+		} else {
+			earlier++
+		}
+	}
+	weeklyRate := float64(earlier) / (float64(anomalyWindowDays-7) / 7)
+	if thisWeek >= anomalyMinWeekCount && float64(thisWeek) > weeklyRate+3*math.Sqrt(math.Max(weeklyRate, 1)) {
+		// One frequency alert per category per week is enough
+		var recent bool
+		err := q.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM anomalies WHERE user_id=$1 AND kind=$2 AND category=$3 AND created_at > now() - interval '7 days')",
+			userID, anomalyFrequency, s.Category).Scan(&recent)
+		if err != nil {
+			return nil, err
+		}
+		if !recent {
+			found = append(found, Anomaly{
+				Kind: anomalyFrequency, Baseline: math.Round(weeklyRate*10) / 10, Score: float64(thisWeek),
+				Explanation: fmt.Sprintf("%d %s transactions in the last 7 days; you usually have about %.1f a week.",
+					thisWeek, s.Category, weeklyRate),
+			})
+		}
+	}
+
+	recorded := make([]Anomaly, 0, len(found))
+	for _, a := range found {
+		a, err := scanAnomaly(q.QueryRow(ctx,
+			"INSERT INTO anomalies (user_id, subject_type, subject_id, kind, category, amount, baseline, score, explanation) "+
+				"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (subject_type, subject_id, kind) DO NOTHING RETURNING "+anomalyColumns,
+			userID, s.Type, s.ID, a.Kind, s.Category, s.Amount, a.Baseline, a.Score, a.Explanation))
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // already flagged
+		}
+		if err != nil {
+			return recorded, err
+		}
+		if err := notify(ctx, q, userID, "anomaly", "Unusual transaction: "+a.Explanation, s.Type, s.ID); err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, a)
+	}
+	return recorded, nil
+}
+
+// checkAnomalies runs detection after a transaction is saved. Failures are logged rather than
+// failing the request that saved it.
+func checkAnomalies(ctx context.Context, userID int, s anomalySubject) {
+	if _, err := detectAnomalies(ctx, db, userID, s); err != nil {
+		fmt.Println("[ANOMALY ERROR]", err)
+	}
+}
+
+func registerAnomalyRoutes(auth *gin.RouterGroup) {
+	// Flagged transactions, newest first; ?status=open or ?status=dismissed filters them
+	auth.GET("/anomalies", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		query := "SELECT " + anomalyColumns + " FROM anomalies WHERE user_id=$1"
+		args := []any{userID}
+		if status := c.Query("status"); status != "" {
+			query += " AND status=$2"
+			args = append(args, status)
+		}
+		rows, err := db.Query(context.Background(), query+" ORDER BY created_at DESC LIMIT 200", args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		anomalies := make([]Anomaly, 0)
+		for rows.Next() {
+			if a, err := scanAnomaly(rows); err == nil {
+				anomalies = append(anomalies, a)
+			}
+		}
+		c.JSON(http.StatusOK, anomalies)
+	})
+
+	// Mark an anomaly as expected
+	auth.POST("/anomalies/:id/dismiss", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "UPDATE anomalies SET status='dismissed' WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss anomaly"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Anomaly not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Dismissed"})
+	})
+
+	// Check the last 30 days of expenses, for transactions saved before detection existed
+	auth.POST("/anomalies/scan", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		rows, err := db.Query(ctx,
+			"SELECT id, category, amount, date FROM expenses WHERE user_id=$1 AND date >= CURRENT_DATE - 30 ORDER BY date, id", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		var subjects []anomalySubject
+		for rows.Next() {
+			s := anomalySubject{Type: "expense"}
+			if err := rows.Scan(&s.ID, &s.Category, &s.Amount, &s.Date); err == nil {
+				subjects = append(subjects, s)
+			}
+		}
+		rows.Close()
+		found := make([]Anomaly, 0)
+		for _, s := range subjects {
+			a, err := detectAnomalies(ctx, db, userID, s)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan for anomalies"})
+				return
+			}
+			found = append(found, a...)
+		}
+		c.JSON(http.StatusOK, gin.H{"scanned": len(subjects), "anomalies": found})
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAmountScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		x         float64
+		past      []float64
+		wantScore float64
+		wantMed   float64
+	}{
+		{"spread, above median", 500, []float64{300, 100, 200}, 2.0235, 200},
+		{"even count, at median", 250, []float64{100, 200, 300, 400}, 0, 250},
+		{"even count, below median", 50, []float64{100, 200, 300, 400}, -1.349, 250},
+		{"zero MAD uses mean deviation", 300, []float64{100, 100, 100, 100, 200}, 7.9788, 100},
+		{"zero MAD, usual amount", 100, []float64{100, 100, 100, 100, 200}, 0, 100},
+		{"identical past, over three times", 400, []float64{100, 100, 100}, anomalyZThreshold + 1, 100},
+		{"identical past, under three times", 250, []float64{100, 100, 100}, 0, 100},
+	}
+	for _, tt := range tests {
+		score, med := amountScore(tt.x, tt.past)
+		if math.Abs(score-tt.wantScore) > 1e-3 || med != tt.wantMed {
+			t.Errorf("%s: amountScore = (%v, %v), want (%v, %v)", tt.name, score, med, tt.wantScore, tt.wantMed)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiError is an error that should reach the client with a specific status and message.
+// Handlers return it from inside transactions so a rollback and the response go together.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, message string) error {
+	return &apiError{Status: status, Message: message}
+}
+
+// respondError writes err as a JSON error response. apiErrors keep their status and message,
+// anything else is reported as a 500 with the fallback message so DB details are not leaked.
+func respondError(c *gin.Context, err error, fallback string) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		c.JSON(apiErr.Status, gin.H{"error": apiErr.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
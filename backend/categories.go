@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// defaultCategories are given to every user, in the order the frontend lists them
+var defaultCategories = []string{
+	"Food", "Transport", "Groceries", "Entertainment", "Utilities", "Shopping", "Health", "Education", "Travel", "Bills",
+	"Subscriptions", "Gifts", "Insurance", "Rent", "Salary", "Investment", "Charity", "Pets", "Kids", "Personal Care",
+	"Beauty", "Clothing", "Recharge", "Petrol", "Home Items", "Stationary", "Phone Accessory", "Laptop and Computer Accessory", "Other",
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Category is one of a user's expense categories. A category with a parent is a
+// subcategory, e.g. Fuel under Transport.
+type Category struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	ParentID  *int      `json:"parent_id"`
+	Color     string    `json:"color"`
+	Icon      string    `json:"icon"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const categoryColumns = "id, user_id, name, parent_id, color, icon, created_at"
+
+func scanCategory(row pgx.Row) (Category, error) {
+	var cat Category
+	err := row.Scan(&cat.ID, &cat.UserID, &cat.Name, &cat.ParentID, &cat.Color, &cat.Icon, &cat.CreatedAt)
+	return cat, err
+}
+
+// ensureDefaultCategories gives a user without categories the default set, plus any
+// category names already used on their expenses and payments. Names differing only in case
+// or a plural "s"/"es" become one category, and the user's rows are rewritten to its name.
+func ensureDefaultCategories(ctx context.Context, q querier, userID int) error {
+	var seeded bool
+	if err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE user_id=$1)", userID).Scan(&seeded); err != nil || seeded {
+		return err
+	}
+	// Most used spelling first, so it is the one kept
+	rows, err := q.Query(ctx,
+		"SELECT category FROM (SELECT category FROM expenses WHERE user_id=$1 AND category<>'' "+
+			"UNION ALL SELECT category FROM payments WHERE user_id=$1 AND category<>'') used "+
+			"GROUP BY category ORDER BY count(*) DESC, category", userID)
+	if err != nil {
+		return err
+	}
+	var used []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		used = append(used, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	names, aliases := canonicalCategoryNames(append(append([]string{}, defaultCategories...), used...))
+	if _, err := q.Exec(ctx,
+		"INSERT INTO categories (user_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING", userID, names); err != nil {
+		return err
+	}
+	for alias, canonical := range aliases {
+		if err := repointCategory(ctx, q, userID, alias, canonical); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// categoryKeys are the lower-cased forms a category name is matched by: itself, and without
+// a plural "s" or "es"
+func categoryKeys(name string) []string {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	keys := []string{lower}
+	if strings.HasSuffix(lower, "es") {
+		keys = append(keys, lower[:len(lower)-2])
+	}
+	if strings.HasSuffix(lower, "s") {
+		keys = append(keys, lower[:len(lower)-1])
+	}
+	return keys
+}
+
+// canonicalCategoryNames collapses names that differ only in case or a plural ending into the
+// first of them. It returns the names kept, in order, and each dropped name's replacement.
+func canonicalCategoryNames(names []string) ([]string, map[string]string) {
+	seen := make(map[string]string)
+	var kept []string
+	aliases := make(map[string]string)
+	for _, name := range names {
+		keys := categoryKeys(name)
+		canonical := ""
+		for _, k := range keys {
+			if c, ok := seen[k]; ok {
+				canonical = c
+				break
+			}
+		}
+		if canonical != "" {
+			if canonical != name {
+				aliases[name] = canonical
+			}
+			continue
+		}
+		kept = append(kept, name)
+		for _, k := range keys {
+			seen[k] = name
+		}
+	}
+	return kept, aliases
+}
+
+// resolveCategory returns the user's canonical spelling of a category name, matching
+// case-insensitively and ignoring a plural "s"/"es" ("foods" is Food). Unknown names are a 400.
+func resolveCategory(ctx context.Context, q querier, userID int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	if err := ensureDefaultCategories(ctx, q, userID); err != nil {
+		return "", err
+	}
+	for _, cand := range categoryKeys(name) {
+		var canonical string
+		err := q.QueryRow(ctx, "SELECT name FROM categories WHERE user_id=$1 AND lower(name)=lower($2)", userID, cand).Scan(&canonical)
+		if err == nil {
+			return canonical, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return "", err
+		}
+	}
+	return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("Unknown category %q", name))
+}
+
+// validateCategoryFields checks the optional colour and icon of a category
+func validateCategoryFields(color, icon string) error {
+	if color != "" && !colorPattern.MatchString(color) {
+		return newAPIError(http.StatusBadRequest, "color must look like #1a2b3c")
+	}
+	if len(icon) > 50 {
+		return newAPIError(http.StatusBadRequest, "icon too long")
+	}
+	return nil
+}
+
+// checkCategoryParent verifies parentID is another of the user's categories and that making it
+// the parent of id (0 for a new category) does not create a cycle
+func checkCategoryParent(ctx context.Context, tx pgx.Tx, userID, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	for cur := parentID; cur != nil; {
+		if *cur == id {
+			return newAPIError(http.StatusBadRequest, "A category cannot be nested under itself")
+		}
+		var next *int
+		err := tx.QueryRow(ctx, "SELECT parent_id FROM categories WHERE id=$1 AND user_id=$2", *cur, userID).Scan(&next)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newAPIError(http.StatusBadRequest, "Parent category not found")
+		}
+		if err != nil {
+			return err
+		}
+		cur = next
+	}
+	return nil
+}
+
+// lockOwnedCategory loads and row-locks a category, failing with 404 unless it belongs to userID
+func lockOwnedCategory(ctx context.Context, tx pgx.Tx, id, userID int) (Category, error) {
+	cat, err := scanCategory(tx.QueryRow(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id=$1 AND user_id=$2 FOR UPDATE", id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cat, newAPIError(http.StatusNotFound, "Category not found")
+	}
+	return cat, err
+}
+
+// repointCategory moves everything filed under category from, in any case, to category to
+func repointCategory(ctx context.Context, q querier, userID int, from, to string) error {
+	for _, table := range []string{"expenses", "payments", "category_rules", "merchant_mappings", "savings_goals"} {
+		if _, err := q.Exec(ctx, "UPDATE "+table+" SET category=$1 WHERE user_id=$2 AND lower(category)=lower($3) AND category<>$1", to, userID, from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func registerCategoryRoutes(auth *gin.RouterGroup) {
+	auth.GET("/categories", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		if err := ensureDefaultCategories(ctx, db, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		rows, err := db.Query(ctx, "SELECT "+categoryColumns+" FROM categories WHERE user_id=$1 ORDER BY id", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		categories := make([]Category, 0)
+		for rows.Next() {
+			if cat, err := scanCategory(rows); err == nil {
+				categories = append(categories, cat)
+			}
+		}
+		c.JSON(http.StatusOK, categories)
+	})
+
+	auth.POST("/categories", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req Category
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name required"})
+			return
+		}
+		var cat Category
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			if err := validateCategoryFields(req.Color, req.Icon); err != nil {
+				return err
+			}
+			if err := ensureDefaultCategories(ctx, tx, userID); err != nil {
+				return err
+			}
+			if err := checkCategoryParent(ctx, tx, userID, 0, req.ParentID); err != nil {
+				return err
+			}
+			var err error
+			cat, err = scanCategory(tx.QueryRow(ctx,
+				"INSERT INTO categories (user_id, name, parent_id, color, icon) VALUES ($1, $2, $3, $4, $5) RETURNING "+categoryColumns,
+				userID, req.Name, req.ParentID, req.Color, req.Icon))
+			if isUniqueViolation(err) {
+				return newAPIError(http.StatusConflict, "Category already exists")
+			}
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add category")
+			return
+		}
+		c.JSON(http.StatusCreated, cat)
+	})
+
+	// Update a category. Renaming it re-points expenses, payments, rules and learned mappings.
+	auth.PUT("/categories/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req Category
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name required"})
+			return
+		}
+		var cat Category
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			if err := validateCategoryFields(req.Color, req.Icon); err != nil {
+				return err
+			}
+			var err error
+			cat, err = lockOwnedCategory(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			if err := checkCategoryParent(ctx, tx, userID, cat.ID, req.ParentID); err != nil {
+				return err
+			}
+			if req.Name != cat.Name {
+				if _, err := tx.Exec(ctx, "UPDATE categories SET name=$1 WHERE id=$2", req.Name, cat.ID); isUniqueViolation(err) {
+					return newAPIError(http.StatusConflict, "Another category already has that name; merge them instead")
+				} else if err != nil {
+					return err
+				}
+				if err := repointCategory(ctx, tx, userID, cat.Name, req.Name); err != nil {
+					return err
+				}
+			}
+			cat, err = scanCategory(tx.QueryRow(ctx,
+				"UPDATE categories SET parent_id=$1, color=$2, icon=$3 WHERE id=$4 RETURNING "+categoryColumns,
+				req.ParentID, req.Color, req.Icon, cat.ID))
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update category")
+			return
+		}
+		c.JSON(http.StatusOK, cat)
+	})
+
+	// Merge this category into another: everything filed under it moves to the target,
+	// its subcategories move under the target, and it is deleted
+	auth.POST("/categories/:id/merge", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			IntoID int `json:"into_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.IntoID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "into_id required"})
+			return
+		}
+		id := atoi(c.Param("id"))
+		if req.IntoID == id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a category into itself"})
+			return
+		}
+		var into Category
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			from, err := lockOwnedCategory(ctx, tx, id, userID)
+			if err != nil {
+				return err
+			}
+			into, err = lockOwnedCategory(ctx, tx, req.IntoID, userID)
+			if err != nil {
+				return err
+			}
+			// The target may not sit below the merged category, or re-parenting would form a cycle
+			var apiErr *apiError
+			if err := checkCategoryParent(ctx, tx, userID, from.ID, &into.ID); errors.As(err, &apiErr) {
+				return newAPIError(http.StatusBadRequest, "Cannot merge a category into one of its subcategories")
+			} else if err != nil {
+				return err
+			}
+			if err := repointCategory(ctx, tx, userID, from.Name, into.Name); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "UPDATE categories SET parent_id=$1 WHERE parent_id=$2 AND user_id=$3", into.ID, from.ID, userID); err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM categories WHERE id=$1", from.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to merge categories")
+			return
+		}
+		c.JSON(http.StatusOK, into)
+	})
+
+	// Delete an unused category; categories in use must be merged into another
+	auth.DELETE("/categories/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			cat, err := lockOwnedCategory(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			var inUse bool
+			err = tx.QueryRow(ctx,
+				"SELECT EXISTS (SELECT 1 FROM expenses WHERE user_id=$1 AND lower(category)=lower($2)) OR EXISTS (SELECT 1 FROM payments WHERE user_id=$1 AND lower(category)=lower($2)) "+
+					"OR EXISTS (SELECT 1 FROM savings_goals WHERE user_id=$1 AND lower(category)=lower($2)) OR EXISTS (SELECT 1 FROM categories WHERE parent_id=$3)",
+				userID, cat.Name, cat.ID).Scan(&inUse)
+			if err != nil {
+				return err
+			}
+			if inUse {
+				return newAPIError(http.StatusConflict, "Category is in use; merge it into another category instead")
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM categories WHERE id=$1", cat.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to delete category")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	})
+}
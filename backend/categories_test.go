@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCanonicalCategoryNames(t *testing.T) {
+	tests := []struct {
+		names       []string
+		wantKept    string
+		wantAliases string
+	}{
+		{[]string{"Food", "Travel"}, "[Food Travel]", "map[]"},
+		{[]string{"Food", "food", "Foods"}, "[Food]", "map[Foods:Food food:Food]"},
+		{[]string{"Groceries", "Grocery"}, "[Groceries Grocery]", "map[]"}, // only "s" and "es" plurals fold
+		{[]string{"Bus", "Buses", "bus"}, "[Bus]", "map[Buses:Bus bus:Bus]"},
+		{[]string{"Bills", "Bill", "Utilities"}, "[Bills Utilities]", "map[Bill:Bills]"},
+		{nil, "[]", "map[]"},
+	}
+	for _, tt := range tests {
+		kept, aliases := canonicalCategoryNames(tt.names)
+		if fmt.Sprint(kept) != tt.wantKept || fmt.Sprint(aliases) != tt.wantAliases {
+			t.Errorf("canonicalCategoryNames(%v) = %v, %v; want %s, %s", tt.names, kept, aliases, tt.wantKept, tt.wantAliases)
+		}
+	}
+}
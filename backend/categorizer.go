@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Where a predicted category came from
+const (
+	sourceUser     = "user"
+	sourceRule     = "rule"
+	sourceLearned  = "learned" // a merchant mapping learned from the user's corrections
+	sourceML       = "ml"
+	sourceFallback = "fallback"
+)
+
+// categoryPrediction is the result of categorizing one description
+type categoryPrediction struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source"`
+}
+
+// errCircuitOpen is returned without calling the AI service while the breaker is open
+var errCircuitOpen = errors.New("AI service circuit breaker open")
+
+// circuitBreaker stops calls to a failing dependency for a cooldown period.
+// After the cooldown a single trial call is let through (half-open); its outcome
+// closes the breaker again or restarts the cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may be made now
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// abandon ends a call whose outcome is unknown, freeing the half-open trial without
+// counting a failure
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// predictionCacheSize bounds the number of cached predictions
+const predictionCacheSize = 10000
+
+// predictionCache keeps AI service predictions by normalized description for a while,
+// so repeated descriptions (a month of "Swiggy order") cost one call
+type predictionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedPrediction
+}
+
+type cachedPrediction struct {
+	prediction categoryPrediction
+	expires    time.Time
+}
+
+func newPredictionCache(ttl time.Duration) *predictionCache {
+	return &predictionCache{ttl: ttl, entries: make(map[string]cachedPrediction)}
+}
+
+func (pc *predictionCache) get(key string) (categoryPrediction, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	e, ok := pc.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return categoryPrediction{}, false
+	}
+	return e.prediction, true
+}
+
+func (pc *predictionCache) put(key string, p categoryPrediction) {
+	if pc.ttl <= 0 {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	now := time.Now()
+	if len(pc.entries) >= predictionCacheSize {
+		for k, e := range pc.entries {
+			if now.After(e.expires) {
+				delete(pc.entries, k)
+			}
+		}
+		// Still full: start over rather than track recency
+		if len(pc.entries) >= predictionCacheSize {
+			pc.entries = make(map[string]cachedPrediction)
+		}
+	}
+	pc.entries[key] = cachedPrediction{prediction: p, expires: now.Add(pc.ttl)}
+}
+
+// categorizerClient calls the Python categorization service (ai-categorizer/ai_service.py)
+type categorizerClient struct {
+	baseURL string
+	http    *http.Client
+	timeout time.Duration
+	retries int
+	breaker *circuitBreaker
+	cache   *predictionCache
+}
+
+// categorizer is the shared client for the AI categorization service
+var categorizer *categorizerClient
+
+func newCategorizerClient(baseURL string, timeout time.Duration, retries int, cacheTTL time.Duration, breakerFailures int, breakerCooldown time.Duration) *categorizerClient {
+	return &categorizerClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{},
+		timeout: timeout,
+		retries: retries,
+		breaker: newCircuitBreaker(breakerFailures, breakerCooldown),
+		cache:   newPredictionCache(cacheTTL),
+	}
+}
+
+// retryableError marks failures worth another attempt (network errors, timeouts, 5xx)
+type retryableError struct{ err error }
+
+func (e retryableError) Error() string { return e.err.Error() }
+
+// predict asks the AI service for a category, retrying transient failures with backoff
+func (cl *categorizerClient) predict(ctx context.Context, description string) (categoryPrediction, error) {
+	if !cl.breaker.allow() {
+		return categoryPrediction{}, errCircuitOpen
+	}
+	var err error
+	for attempt := 0; attempt <= cl.retries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(100<<attempt) * time.Millisecond
+			select {
+			case <-ctx.Done():
+				cl.breaker.abandon()
+				return categoryPrediction{}, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+		var p categoryPrediction
+		p, err = cl.predictOnce(ctx, description)
+		if err == nil {
+			cl.breaker.success()
+			return p, nil
+		}
+		var retryable retryableError
+		if !errors.As(err, &retryable) {
+			break
+		}
+	}
+	// A caller that went away says nothing about the service
+	if ctx.Err() != nil {
+		cl.breaker.abandon()
+	} else {
+		cl.breaker.failure()
+	}
+	return categoryPrediction{}, err
+}
+
+func (cl *categorizerClient) predictOnce(ctx context.Context, description string) (categoryPrediction, error) {
+	ctx, cancel := context.WithTimeout(ctx, cl.timeout)
+	defer cancel()
+	body, _ := json.Marshal(map[string]string{"description": description})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cl.baseURL+"/categorize", bytes.NewReader(body))
+	if err != nil {
+		return categoryPrediction{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return categoryPrediction{}, retryableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Drain so the connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		err := fmt.Errorf("AI service returned %s", resp.Status)
+		if resp.StatusCode >= 500 {
+			return categoryPrediction{}, retryableError{err}
+		}
+		return categoryPrediction{}, err
+	}
+	var out struct {
+		Category   string  `json:"category"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return categoryPrediction{}, err
+	}
+	if out.Category == "" {
+		return categoryPrediction{}, errors.New("AI service returned no category")
+	}
+	return categoryPrediction{Category: out.Category, Confidence: out.Confidence, Source: sourceML}, nil
+}
+
+// fallbackCategorize guesses a category from the keyword dictionary when the AI service cannot be reached
+func fallbackCategorize(description string) categoryPrediction {
+	if p, ok := dictionaryCategorize(description); ok {
+		p.Source = sourceFallback
+		return p
+	}
+	return categoryPrediction{Category: "Other", Confidence: 0, Source: sourceFallback}
+}
+
+// Categorize returns the AI service's prediction, or the keyword fallback when the service
+// is down, slow or its circuit breaker is open. It never fails. Predictions are cached by
+// normalized description; fallbacks are not, so they are retried once the service recovers.
+func (cl *categorizerClient) Categorize(ctx context.Context, description string) categoryPrediction {
+	key := normalizeDescription(description)
+	if p, ok := cl.cache.get(key); ok {
+		return p
+	}
+	p, err := cl.predict(ctx, description)
+	if err != nil {
+		if !errors.Is(err, errCircuitOpen) {
+			fmt.Println("[AI CATEGORIZER] Falling back to keywords:", err)
+		}
+		return fallbackCategorize(description)
+	}
+	cl.cache.put(key, p)
+	return p
+}
+
+// maxBatchSize is the most descriptions accepted by one batch request
+const maxBatchSize = 1000
+
+// batchItem is one description to categorize in a batch
+type batchItem struct {
+	Description string   `json:"description"`
+	Amount      *float64 `json:"amount"`
+}
+
+// batchResult is the outcome for one batch item; Error is set instead of a category when it failed
+type batchResult struct {
+	Index       int     `json:"index"`
+	Description string  `json:"description"`
+	Category    string  `json:"category,omitempty"`
+	Confidence  float64 `json:"confidence"`
+	Source      string  `json:"source,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// categorizeBatch categorizes many descriptions for a user (0 skips the per-user steps). Rules, learned
+// mappings and the dictionary are applied per item; the rest go to the AI service once per
+// distinct normalized description, with at most cfg.AIConcurrency calls in flight.
+func categorizeBatch(ctx context.Context, userID int, items []batchItem) ([]batchResult, error) {
+	var rules []CategoryRule
+	if userID != 0 {
+		var err error
+		if rules, err = loadCategoryRules(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	results := make([]batchResult, len(items))
+	pending := make(map[string][]int) // normalized description -> indexes waiting on the AI service
+	for i, it := range items {
+		results[i] = batchResult{Index: i, Description: it.Description}
+		key := normalizeDescription(it.Description)
+		if key == "" {
+			results[i].Error = "Description required"
+			continue
+		}
+		p, ok, err := localCategorize(ctx, userID, rules, it.Description, it.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			results[i].Category, results[i].Confidence, results[i].Source = p.Category, p.Confidence, p.Source
+			continue
+		}
+		pending[key] = append(pending[key], i)
+	}
+
+	concurrency := cfg.AIConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, idxs := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idxs []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p := categorizer.Categorize(ctx, items[idxs[0]].Description)
+			// Each index is written by exactly one goroutine
+			for _, i := range idxs {
+				results[i].Category, results[i].Confidence, results[i].Source = p.Category, p.Confidence, p.Source
+			}
+		}(idxs)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func registerCategorizerRoutes(auth *gin.RouterGroup) {
+	// Categorization endpoint. Rules are tried first (the caller's own rules, then the
+	// built-in dictionary); the Python AI microservice only sees the rest.
+	auth.POST("/ai/categorize", func(c *gin.Context) {
+		var req struct {
+			Description string   `json:"description"`
+			Amount      *float64 `json:"amount"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Description == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Description required"})
+			return
+		}
+		p, err := categorizeDescription(c.Request.Context(), getUserIDFromToken(c), req.Description, req.Amount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load category rules"})
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+
+	// Categorize up to maxBatchSize descriptions in one call. Results are in request order;
+	// an item that cannot be categorized carries an error instead of failing the batch.
+	auth.POST("/ai/categorize/batch", func(c *gin.Context) {
+		var req struct {
+			Items []batchItem `json:"items"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "items required"})
+			return
+		}
+		if len(req.Items) > maxBatchSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d items per batch", maxBatchSize)})
+			return
+		}
+		results, err := categorizeBatch(c.Request.Context(), getUserIDFromToken(c), req.Items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load category rules"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// Each step is "allow" (expecting want), "ok", "fail" or "expire" (end the cooldown)
+	type step struct {
+		op   string
+		want bool
+	}
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []step
+	}{
+		{"closed below threshold", 3, []step{{"fail", false}, {"fail", false}, {"allow", true}}},
+		{"opens at threshold", 2, []step{{"fail", false}, {"fail", false}, {"allow", false}}},
+		{"success resets failures", 2, []step{{"fail", false}, {"ok", false}, {"fail", false}, {"allow", true}}},
+		{"single trial after cooldown", 1, []step{{"fail", false}, {"expire", false}, {"allow", true}, {"allow", false}}},
+		{"trial success closes", 1, []step{{"fail", false}, {"expire", false}, {"allow", true}, {"ok", false}, {"allow", true}, {"allow", true}}},
+		{"trial failure reopens", 1, []step{{"fail", false}, {"expire", false}, {"allow", true}, {"fail", false}, {"allow", false}}},
+		{"threshold clamped to one", 0, []step{{"allow", true}, {"fail", false}, {"allow", false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(tt.threshold, time.Hour)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if got := b.allow(); got != s.want {
+						t.Fatalf("step %d: allow = %v, want %v", i, got, s.want)
+					}
+				case "ok":
+					b.success()
+				case "fail":
+					b.failure()
+				case "expire":
+					b.openUntil = time.Now().Add(-time.Second)
+				}
+			}
+		})
+	}
+}
+
+// fakeAIService answers /categorize with "AI:<description>" after a short delay, and records
+// the calls it received and the most it had in flight at once
+type fakeAIService struct {
+	mu       sync.Mutex
+	calls    []string
+	inFlight int
+	peak     int
+}
+
+func (f *fakeAIService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Description string `json:"description"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	f.mu.Lock()
+	f.calls = append(f.calls, req.Description)
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{"category": "AI:" + req.Description, "confidence": 0.9})
+}
+
+func TestCategorizeBatch(t *testing.T) {
+	ai := &fakeAIService{}
+	srv := httptest.NewServer(ai)
+	defer srv.Close()
+	prevCategorizer, prevConcurrency := categorizer, cfg.AIConcurrency
+	categorizer = newCategorizerClient(srv.URL, time.Second, 0, time.Minute, 5, time.Minute)
+	cfg.AIConcurrency = 2
+	defer func() { categorizer, cfg.AIConcurrency = prevCategorizer, prevConcurrency }()
+
+	items := []batchItem{
+		{Description: "Qwerty vendor"},
+		{Description: "  "},
+		{Description: "Uber to office"},
+		{Description: "qwerty  VENDOR!"}, // same normalized description as the first
+	}
+	for i := 0; i < 6; i++ {
+		items = append(items, batchItem{Description: fmt.Sprintf("zxq store %d", i)})
+	}
+	results, err := categorizeBatch(context.Background(), 0, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(items) {
+		t.Fatalf("got %d results for %d items", len(results), len(items))
+	}
+	for i, r := range results {
+		if r.Index != i || r.Description != items[i].Description {
+			t.Errorf("result %d is for item %d (%q)", i, r.Index, r.Description)
+		}
+	}
+	want := map[int]batchResult{
+		0: {Category: "AI:Qwerty vendor", Source: sourceML},
+		1: {Error: "Description required"},
+		2: {Category: "Transport", Source: sourceRule},
+		3: {Category: "AI:Qwerty vendor", Source: sourceML},
+		9: {Category: "AI:zxq store 5", Source: sourceML},
+	}
+	for i, w := range want {
+		if r := results[i]; r.Category != w.Category || r.Source != w.Source || r.Error != w.Error {
+			t.Errorf("result %d = %+v, want category %q, source %q, error %q", i, r, w.Category, w.Source, w.Error)
+		}
+	}
+	// One call per distinct description the rules didn't settle, never more than two at a time
+	if len(ai.calls) != 7 {
+		t.Errorf("AI service called %d times (%v), want 7", len(ai.calls), ai.calls)
+	}
+	if ai.peak > 2 {
+		t.Errorf("%d calls in flight at once, want at most 2", ai.peak)
+	}
+}
+
+func TestPredictCancelledCallerIsNotAFailure(t *testing.T) {
+	srv := httptest.NewServer(&fakeAIService{})
+	defer srv.Close()
+	cl := newCategorizerClient(srv.URL, time.Second, 1, time.Minute, 1, time.Hour)
+	// Half-open: the next call is the trial
+	cl.breaker.failures, cl.breaker.openUntil = 1, time.Now().Add(-time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cl.predict(ctx, "qwerty vendor"); err == nil {
+		t.Fatal("predict succeeded with a cancelled context")
+	}
+	if cl.breaker.failures != 1 || cl.breaker.openUntil.After(time.Now()) {
+		t.Errorf("cancelled call counted as a failure: failures %d, open until %v", cl.breaker.failures, cl.breaker.openUntil)
+	}
+	// The trial slot is free again
+	p, err := cl.predict(context.Background(), "qwerty vendor")
+	if err != nil || !strings.HasPrefix(p.Category, "AI:") {
+		t.Errorf("predict after cancellation = %+v, %v", p, err)
+	}
+}
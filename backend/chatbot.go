@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Chatbot intents answered from the caller's own data
+const (
+	intentLastWeek          = "spend_last_week"
+	intentLastMonth         = "spend_last_month"
+	intentExpensesThisMonth = "expenses_this_month"
+	intentSpendThisMonth    = "spend_this_month"
+	intentSavings           = "savings"
+	intentTrend             = "trend"
+	intentTips              = "tips"
+)
+
+// chatbotIntents are checked in order; the first whose phrases appear in the question wins
+var chatbotIntents = []struct {
+	Intent  string
+	Phrases []string
+}{
+	{intentSavings, []string{"saving", "savings", "save", "budget left", "left in my budget"}},
+	{intentTrend, []string{"trend", "compared to last month", "more than last month", "less than last month"}},
+	{intentLastWeek, []string{"last week", "past week", "previous week", "last 7 days"}},
+	{intentLastMonth, []string{"last month", "past month", "previous month"}},
+	{intentExpensesThisMonth, []string{"expenses this month", "expense this month"}},
+	{intentSpendThisMonth, []string{"spent this month", "spending this month", "spend this month", "payments this month", "paid this month"}},
+	{intentTips, []string{"tip", "tips", "advice", "health", "suggest"}},
+}
+
+// detectIntent returns the intent of a question, or "" when none matches
+func detectIntent(question string) string {
+	text := normalizeDescription(question)
+	for _, ci := range chatbotIntents {
+		for _, phrase := range ci.Phrases {
+			if containsPhrase(text, phrase) {
+				return ci.Intent
+			}
+		}
+	}
+	return ""
+}
+
+// LLMClient answers free-form questions the chatbot has no intent for. Only the question
+// is sent, never the user's financial data.
+type LLMClient interface {
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// chatLLM is the configured fallback, nil when CHATBOT_LLM is unset
+var chatLLM LLMClient
+
+// newLLMClient builds the fallback selected by CHATBOT_LLM
+func newLLMClient(c Config) (LLMClient, error) {
+	switch c.ChatbotLLM {
+	case "":
+		return nil, nil
+	case "huggingface":
+		return &huggingFaceLLM{url: c.ChatbotLLMURL, token: c.ChatbotLLMToken, http: &http.Client{Timeout: c.ChatbotLLMTimeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown CHATBOT_LLM %q", c.ChatbotLLM)
+}
+
+// huggingFaceLLM calls a text generation model on the Hugging Face inference API
+type huggingFaceLLM struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func (h *huggingFaceLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	body, _ := json.Marshal(map[string]string{"inputs": prompt})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var out []struct {
+		GeneratedText string `json:"generated_text"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || len(out) == 0 || out[0].GeneratedText == "" {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error != "" {
+			return "", errors.New(apiErr.Error)
+		}
+		return "", fmt.Errorf("unexpected LLM response (%s)", resp.Status)
+	}
+	return out[0].GeneratedText, nil
+}
+
+var financialTips = []string{
+	"Track your expenses regularly to avoid overspending.",
+	"Set a monthly budget and try to save at least 20% of your income.",
+	"Review your subscriptions and cancel those you don't use.",
+	"Plan for emergencies by building an emergency fund.",
+	"Use digital tools to automate bill payments and savings.",
+}
+
+// monthStart is the first day of the month containing t
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// sumExpenses totals a user's expenses dated in [from, to)
+func sumExpenses(ctx context.Context, userID int, from, to time.Time) (float64, error) {
+	var total float64
+	err := db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM expenses WHERE user_id=$1 AND date >= $2 AND date < $3", userID, from, to).Scan(&total)
+	return roundMoney(total), err
+}
+
+// sumPayments totals a user's payments dated in [from, to)
+func sumPayments(ctx context.Context, userID int, from, to time.Time) (float64, error) {
+	var total float64
+	err := db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM payments WHERE user_id=$1 AND payment_date >= $2 AND payment_date < $3", userID, from, to).Scan(&total)
+	return roundMoney(total), err
+}
+
+// savingsAnswer describes this month's savings: income less expenses, or without recorded
+// income, what is left of the monthly budget
+func savingsAnswer(income, expenses, budget float64) string {
+	if income > 0 {
+		net, rate := cashFlow(income, expenses)
+		if net < 0 {
+			return fmt.Sprintf("You have spent ₹%.2f more than your income this month. (Income: ₹%.2f, Expenses: ₹%.2f)", -net, income, expenses)
+		}
+		return fmt.Sprintf("Your savings this month are ₹%.2f, %.0f%% of your income. (Income: ₹%.2f, Expenses: ₹%.2f)", net, *rate*100, income, expenses)
+	}
+	if budget <= 0 {
+		return "You haven't recorded any income or set a monthly budget yet. Add either to see how much you're saving."
+	}
+	savings := roundMoney(budget - expenses)
+	if savings < 0 {
+		return fmt.Sprintf("You are ₹%.2f over budget this month. (Budget: ₹%.2f, Expenses: ₹%.2f)", -savings, budget, expenses)
+	}
+	return fmt.Sprintf("Your estimated savings this month are ₹%.2f. (Budget: ₹%.2f, Expenses: ₹%.2f)", savings, budget, expenses)
+}
+
+// trendAnswer compares this month's expenses with last month's
+func trendAnswer(current, previous float64) string {
+	diff := roundMoney(current - previous)
+	trend := "remained the same"
+	if diff > 0 {
+		trend = "increased"
+	} else if diff < 0 {
+		trend = "decreased"
+	}
+	change := ""
+	if previous > 0 && diff != 0 {
+		change = fmt.Sprintf(" (%.0f%%)", math.Abs(diff)/previous*100)
+	}
+	return fmt.Sprintf("Your spending has %s by ₹%.2f%s compared to last month. (This month: ₹%.2f, Last month: ₹%.2f)",
+		trend, math.Abs(diff), change, current, previous)
+}
+
+// answerIntent answers a data intent from the user's own expenses, payments, income and budget
+func answerIntent(ctx context.Context, userID int, intent string) (string, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	tomorrow := today.AddDate(0, 0, 1)
+	thisMonth := monthStart(now)
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	switch intent {
+	case intentLastWeek:
+		total, err := sumPayments(ctx, userID, today.AddDate(0, 0, -7), tomorrow)
+		return fmt.Sprintf("You have spent ₹%.2f in the last week.", total), err
+
+	case intentLastMonth:
+		total, err := sumPayments(ctx, userID, lastMonth, thisMonth)
+		return fmt.Sprintf("You spent ₹%.2f last month.", total), err
+
+	case intentExpensesThisMonth:
+		total, err := sumExpenses(ctx, userID, thisMonth, thisMonth.AddDate(0, 1, 0))
+		return fmt.Sprintf("Your total expenses this month are ₹%.2f.", total), err
+
+	case intentSpendThisMonth:
+		total, err := sumPayments(ctx, userID, thisMonth, tomorrow)
+		return fmt.Sprintf("You have spent ₹%.2f this month.", total), err
+
+	case intentSavings:
+		expenses, err := sumExpenses(ctx, userID, thisMonth, thisMonth.AddDate(0, 1, 0))
+		if err != nil {
+			return "", err
+		}
+		// Savings are income less expenses; without recorded income, measure against the budget
+		income, _, err := sumIncome(ctx, userID, thisMonth, thisMonth.AddDate(0, 1, -1))
+		if err != nil {
+			return "", err
+		}
+		var budget float64
+		if income <= 0 {
+			if err := db.QueryRow(ctx, "SELECT COALESCE(budget, 0) FROM users WHERE id=$1", userID).Scan(&budget); err != nil {
+				return "", err
+			}
+		}
+		return savingsAnswer(income, expenses, budget), nil
+
+	case intentTrend:
+		current, err := sumExpenses(ctx, userID, thisMonth, thisMonth.AddDate(0, 1, 0))
+		if err != nil {
+			return "", err
+		}
+		previous, err := sumExpenses(ctx, userID, lastMonth, thisMonth)
+		if err != nil {
+			return "", err
+		}
+		return trendAnswer(current, previous), nil
+
+	case intentTips:
+		// Point at the biggest category when it dominates the month, otherwise a general tip
+		var category string
+		var total, all float64
+		err := db.QueryRow(ctx,
+			"SELECT category, SUM(amount), (SELECT COALESCE(SUM(amount), 0) FROM expenses WHERE user_id=$1 AND date >= $2) "+
+				"FROM expenses WHERE user_id=$1 AND date >= $2 GROUP BY category ORDER BY SUM(amount) DESC LIMIT 1",
+			userID, thisMonth).Scan(&category, &total, &all)
+		if err == nil && all > 0 && total/all >= 0.4 {
+			return fmt.Sprintf("Financial Health Tip: %s makes up %.0f%% of your spending this month (₹%.2f). Look there first for savings.",
+				category, total/all*100, roundMoney(total)), nil
+		}
+		return "Financial Health Tip: " + financialTips[rand.Intn(len(financialTips))], nil
+	}
+	return "", fmt.Errorf("unknown intent %q", intent)
+}
+
+func registerChatbotRoutes(auth *gin.RouterGroup) {
+	// Answers questions about the caller's own spending; anything else goes to the LLM
+	// fallback when one is configured
+	auth.POST("/chatbot", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Message string `json:"message"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Message) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "message required"})
+			return
+		}
+		if intent := detectIntent(req.Message); intent != "" {
+			answer, err := answerIntent(c.Request.Context(), userID, intent)
+			if err != nil {
+				fmt.Println("[CHATBOT ERROR]", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"answer": answer, "intent": intent, "source": "intent"})
+			return
+		}
+		if chatLLM == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"answer": "I can tell you about your spending last week or month, your savings, trends and tips. Try \"How much did I spend last week?\"",
+				"source": "none",
+			})
+			return
+		}
+		answer, err := chatLLM.Complete(c.Request.Context(), req.Message)
+		if err != nil {
+			fmt.Println("[CHATBOT LLM ERROR]", err)
+			c.JSON(http.StatusOK, gin.H{"answer": "Sorry, I could not answer that right now. Please try again later.", "source": "none"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"answer": answer, "source": "llm"})
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDetectIntent(t *testing.T) {
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"How much did I spend last week?", intentLastWeek},
+		{"expenses in the last 7 days", intentLastWeek},
+		{"What did I spend LAST MONTH", intentLastMonth},
+		{"Show my expenses this month", intentExpensesThisMonth},
+		{"How much have I spent this month?", intentSpendThisMonth},
+		{"How much can I save?", intentSavings},
+		{"Am I saving more compared to last month?", intentSavings}, // savings is checked first
+		{"Is my spending up compared to last month", intentTrend},
+		{"Any tips?", intentTips},
+		{"How is my financial health", intentTips},
+		// Phrases only count as whole words
+		{"Who won the last weekend match?", ""},
+		{"What is the capital of France?", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := detectIntent(tt.question); got != tt.want {
+			t.Errorf("detectIntent(%q) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
+
+func TestSavingsAnswer(t *testing.T) {
+	tests := []struct {
+		income, expenses, budget float64
+		want                     string
+	}{
+		{50000, 30000, 0, "Your savings this month are ₹20000.00, 40% of your income."},
+		{50000, 60000, 80000, "You have spent ₹10000.00 more than your income this month."},
+		{0, 30000, 40000, "Your estimated savings this month are ₹10000.00."},
+		{0, 45000, 40000, "You are ₹5000.00 over budget this month."},
+		{0, 45000, 0, "You haven't recorded any income or set a monthly budget yet."},
+	}
+	for _, tt := range tests {
+		if got := savingsAnswer(tt.income, tt.expenses, tt.budget); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("savingsAnswer(%v, %v, %v) = %q, want it to start with %q", tt.income, tt.expenses, tt.budget, got, tt.want)
+		}
+	}
+}
+
+func TestTrendAnswer(t *testing.T) {
+	tests := []struct {
+		current, previous float64
+		want              string
+	}{
+		{1200, 1000, "Your spending has increased by ₹200.00 (20%) compared to last month."},
+		{750, 1000, "Your spending has decreased by ₹250.00 (25%) compared to last month."},
+		{1000, 1000, "Your spending has remained the same by ₹0.00 compared to last month."},
+		{500, 0, "Your spending has increased by ₹500.00 compared to last month."},
+	}
+	for _, tt := range tests {
+		if got := trendAnswer(tt.current, tt.previous); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("trendAnswer(%v, %v) = %q, want it to start with %q", tt.current, tt.previous, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerIntentUnknown(t *testing.T) {
+	if _, err := answerIntent(context.Background(), 1, "weather"); err == nil {
+		t.Error("answerIntent accepted an unknown intent")
+	}
+}
+
+// stubLLM records the prompts it is sent
+type stubLLM struct {
+	prompts []string
+	err     error
+}
+
+func (s *stubLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	return "Paris", s.err
+}
+
+func TestChatbotLLMFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerChatbotRoutes(r.Group("/api", authMiddleware()))
+	token, err := generateJWT(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ask := func(message string) map[string]any {
+		raw, _ := json.Marshal(gin.H{"message": message})
+		req := httptest.NewRequest(http.MethodPost, "/api/chatbot", bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		json.Unmarshal(w.Body.Bytes(), &out)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /api/chatbot %q: %d %v", message, w.Code, out)
+		}
+		return out
+	}
+	prev := chatLLM
+	defer func() { chatLLM = prev }()
+
+	chatLLM = nil
+	if out := ask("What is the capital of France?"); out["source"] != "none" {
+		t.Errorf("without an LLM: %v", out)
+	}
+
+	llm := &stubLLM{}
+	chatLLM = llm
+	if out := ask("What is the capital of France?"); out["source"] != "llm" || out["answer"] != "Paris" {
+		t.Errorf("with an LLM: %v", out)
+	}
+	if len(llm.prompts) != 1 || llm.prompts[0] != "What is the capital of France?" {
+		t.Errorf("LLM prompts = %q, want only the question", llm.prompts)
+	}
+
+	llm.err = errors.New("unavailable")
+	if out := ask("What is the capital of France?"); out["source"] != "none" || out["answer"] == "Paris" {
+		t.Errorf("with a failing LLM: %v", out)
+	}
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Expense report statuses. An employee builds a draft report of their expenses and submits it;
+// a manager of the group approves or rejects it; an approved report is payable until reimbursed.
+const (
+	reportDraft      = "draft"
+	reportSubmitted  = "submitted"
+	reportApproved   = "approved"
+	reportRejected   = "rejected"
+	reportReimbursed = "reimbursed"
+)
+
+// reportTransitions lists the statuses each report status may move to. A submitted report can
+// be withdrawn to draft, and a rejected one reopened as a draft to be corrected and resubmitted.
+var reportTransitions = map[string][]string{
+	reportDraft:     {reportSubmitted},
+	reportSubmitted: {reportDraft, reportApproved, reportRejected},
+	reportRejected:  {reportDraft},
+	reportApproved:  {reportReimbursed},
+}
+
+// maxReceiptSize is the largest receipt file accepted
+const maxReceiptSize = 5 << 20
+
+// receiptTypes are the content types accepted for receipts, detected from the file itself
+var receiptTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/webp": true, "application/pdf": true}
+
+// ExpenseReport is a claim for reimbursement of an employee's expenses by their group
+type ExpenseReport struct {
+	ID           int       `json:"id"`
+	GroupID      int       `json:"group_id"`
+	UserID       int       `json:"user_id"`
+	EmployeeName string    `json:"employee_name"`
+	Title        string    `json:"title"`
+	Status       string    `json:"status"`
+	Total        float64   `json:"total"`
+	PaymentID    *int      `json:"payment_id"` // the reimbursement, once paid
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+const reportColumns = "r.id, r.group_id, r.user_id, u.name, r.title, r.status, " +
+	"(SELECT COALESCE(SUM(i.amount), 0) FROM expense_report_items i WHERE i.report_id=r.id), r.payment_id, r.created_at, r.updated_at " +
+	"FROM expense_reports r JOIN users u ON u.id=r.user_id"
+
+func scanReport(row pgx.Row) (ExpenseReport, error) {
+	var r ExpenseReport
+	err := row.Scan(&r.ID, &r.GroupID, &r.UserID, &r.EmployeeName, &r.Title, &r.Status, &r.Total, &r.PaymentID, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// ReportItem is an expense claimed in a report. Amount is the expense's amount when it was
+// added or the report last submitted.
+type ReportItem struct {
+	ExpenseID   int       `json:"expense_id"`
+	Date        string    `json:"date"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Amount      float64   `json:"amount"`
+	Note        string    `json:"note"`
+	Receipts    []Receipt `json:"receipts"`
+}
+
+// Receipt describes an uploaded receipt; the file itself is downloaded separately
+type Receipt struct {
+	ID          int       `json:"id"`
+	ExpenseID   int       `json:"expense_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// ReportTransition records one status change of a report, who made it and why
+type ReportTransition struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    int       `json:"actor_id"`
+	ActorName  string    `json:"actor_name"`
+	Comment    string    `json:"comment"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// isApprover reports whether a group role may approve and reimburse expense reports
+func isApprover(role string) bool {
+	return role == roleOwner || role == roleManager
+}
+
+// reportAccess loads and row-locks a report visible to userID: the employee who made it and
+// the group's approvers can see it; anyone else gets a 404
+func reportAccess(ctx context.Context, tx pgx.Tx, id, userID int) (r ExpenseReport, employee, approver bool, err error) {
+	r, err = scanReport(tx.QueryRow(ctx, "SELECT "+reportColumns+" WHERE r.id=$1 FOR UPDATE OF r", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r, false, false, newAPIError(http.StatusNotFound, "Expense report not found")
+	}
+	if err != nil {
+		return r, false, false, err
+	}
+	employee = r.UserID == userID
+	if role, err := groupRole(ctx, tx, r.GroupID, userID); err == nil {
+		approver = isApprover(role) && !employee
+	} else if !errors.As(err, new(*apiError)) {
+		return r, false, false, err
+	}
+	if !employee && !approver {
+		return r, false, false, newAPIError(http.StatusNotFound, "Expense report not found")
+	}
+	return r, employee, approver, nil
+}
+
+// lockDraftReport loads a report the employee userID can still change
+func lockDraftReport(ctx context.Context, tx pgx.Tx, id, userID int) (ExpenseReport, error) {
+	r, employee, _, err := reportAccess(ctx, tx, id, userID)
+	if err != nil {
+		return r, err
+	}
+	if !employee {
+		return r, newAPIError(http.StatusForbidden, "Only the employee who made the report can change it")
+	}
+	if r.Status != reportDraft {
+		return r, newAPIError(http.StatusConflict, "Only draft reports can be changed")
+	}
+	return r, nil
+}
+
+// transitionReport moves a report to a new status and records who did it, with their comment
+func transitionReport(ctx context.Context, tx pgx.Tx, r *ExpenseReport, to string, actorID int, comment string) error {
+	allowed := false
+	for _, s := range reportTransitions[r.Status] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return newAPIError(http.StatusConflict, fmt.Sprintf("Report cannot move from %s to %s", r.Status, to))
+	}
+	if _, err := tx.Exec(ctx, "UPDATE expense_reports SET status=$1, updated_at=now() WHERE id=$2", to, r.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO expense_report_transitions (report_id, from_status, to_status, actor_id, comment) VALUES ($1, $2, $3, $4, $5)",
+		r.ID, r.Status, to, actorID, comment); err != nil {
+		return err
+	}
+	r.Status = to
+	return nil
+}
+
+// expenseClaimed reports whether an expense is in a report that has been submitted, so it
+// can't be changed under the approver
+func expenseClaimed(ctx context.Context, q querier, expenseID int) (bool, error) {
+	var claimed bool
+	err := q.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM expense_report_items i JOIN expense_reports r ON r.id=i.report_id WHERE i.expense_id=$1 AND r.status NOT IN ($2, $3))",
+		expenseID, reportDraft, reportRejected).Scan(&claimed)
+	return claimed, err
+}
+
+// reportItems loads a report's items with their receipts
+func reportItems(ctx context.Context, q querier, reportID int) ([]ReportItem, error) {
+	rows, err := q.Query(ctx,
+		"SELECT e.id, e.date, e.description, e.category, i.amount, i.note FROM expense_report_items i JOIN expenses e ON e.id=i.expense_id "+
+			"WHERE i.report_id=$1 ORDER BY e.date, e.id", reportID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ReportItem, 0)
+	index := make(map[int]int)
+	for rows.Next() {
+		var it ReportItem
+		var date time.Time
+		if err := rows.Scan(&it.ExpenseID, &date, &it.Description, &it.Category, &it.Amount, &it.Note); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		it.Date = date.Format("2006-01-02")
+		it.Receipts = make([]Receipt, 0)
+		index[it.ExpenseID] = len(items)
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows, err = q.Query(ctx,
+		"SELECT id, expense_id, filename, content_type, size, created_at FROM expense_report_receipts WHERE report_id=$1 ORDER BY id", reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rc Receipt
+		if err := rows.Scan(&rc.ID, &rc.ExpenseID, &rc.Filename, &rc.ContentType, &rc.Size, &rc.UploadedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[rc.ExpenseID]; ok {
+			items[i].Receipts = append(items[i].Receipts, rc)
+		}
+	}
+	return items, rows.Err()
+}
+
+// notifyApprovers tells a group's owner and managers, other than the employee, about a report
+func notifyApprovers(ctx context.Context, q querier, r ExpenseReport, message string) error {
+	rows, err := q.Query(ctx, "SELECT user_id FROM group_members WHERE group_id=$1 AND role IN ($2, $3) AND user_id<>$4",
+		r.GroupID, roleOwner, roleManager, r.UserID)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := notify(ctx, q, id, "expense_report", message, "expense_report", r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listReports lists reports matching where, newest first
+func listReports(ctx context.Context, where string, args ...any) ([]ExpenseReport, error) {
+	rows, err := db.Query(ctx, "SELECT "+reportColumns+" WHERE "+where+" ORDER BY r.updated_at DESC, r.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := make([]ExpenseReport, 0)
+	for rows.Next() {
+		if r, err := scanReport(rows); err == nil {
+			reports = append(reports, r)
+		}
+	}
+	return reports, rows.Err()
+}
+
+func registerClaimRoutes(auth *gin.RouterGroup) {
+	// The user's own expense reports; ?status= filters by status
+	auth.GET("/claims", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		where, args := "r.user_id=$1", []any{userID}
+		if status := c.Query("status"); status != "" {
+			where += " AND r.status=$2"
+			args = append(args, status)
+		}
+		reports, err := listReports(context.Background(), where, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, reports)
+	})
+
+	// Reports the user can act on as an owner or manager: submitted ones awaiting approval by
+	// default, or ?status=approved for those payable
+	auth.GET("/claims/review", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		status := c.DefaultQuery("status", reportSubmitted)
+		reports, err := listReports(context.Background(),
+			"r.status=$1 AND r.user_id<>$2 AND EXISTS (SELECT 1 FROM group_members m WHERE m.group_id=r.group_id AND m.user_id=$2 AND m.role IN ($3, $4))",
+			status, userID, roleOwner, roleManager)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		var total float64
+		for _, r := range reports {
+			total += r.Total
+		}
+		c.JSON(http.StatusOK, gin.H{"reports": reports, "total": roundMoney(total)})
+	})
+
+	// Start a draft report in a group the user belongs to, optionally with some of their expenses
+	auth.POST("/claims", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			GroupID    int    `json:"group_id"`
+			Title      string `json:"title"`
+			ExpenseIDs []int  `json:"expense_ids"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		title := strings.TrimSpace(input.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title required"})
+			return
+		}
+		ctx := context.Background()
+		var r ExpenseReport
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			if _, err := groupRole(ctx, tx, input.GroupID, userID); err != nil {
+				return err
+			}
+			var id int
+			err := tx.QueryRow(ctx, "INSERT INTO expense_reports (group_id, user_id, title, status) VALUES ($1, $2, $3, $4) RETURNING id",
+				input.GroupID, userID, title, reportDraft).Scan(&id)
+			if err != nil {
+				return err
+			}
+			for _, expenseID := range input.ExpenseIDs {
+				if err := addReportItem(ctx, tx, id, expenseID, userID, ""); err != nil {
+					return err
+				}
+			}
+			r, err = scanReport(tx.QueryRow(ctx, "SELECT "+reportColumns+" WHERE r.id=$1", id))
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to create expense report")
+			return
+		}
+		c.JSON(http.StatusCreated, r)
+	})
+
+	// A report with its items, receipts and history
+	auth.GET("/claims/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		var r ExpenseReport
+		var items []ReportItem
+		transitions := make([]ReportTransition, 0)
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			if r, _, _, err = reportAccess(ctx, tx, atoi(c.Param("id")), userID); err != nil {
+				return err
+			}
+			if items, err = reportItems(ctx, tx, r.ID); err != nil {
+				return err
+			}
+			rows, err := tx.Query(ctx,
+				"SELECT t.from_status, t.to_status, t.actor_id, u.name, t.comment, t.changed_at FROM expense_report_transitions t "+
+					"JOIN users u ON u.id=t.actor_id WHERE t.report_id=$1 ORDER BY t.changed_at, t.id", r.ID)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var t ReportTransition
+				if err := rows.Scan(&t.FromStatus, &t.ToStatus, &t.ActorID, &t.ActorName, &t.Comment, &t.ChangedAt); err != nil {
+					return err
+				}
+				transitions = append(transitions, t)
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"report": r, "items": items, "transitions": transitions})
+	})
+
+	auth.PUT("/claims/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			Title string `json:"title"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		title := strings.TrimSpace(input.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title required"})
+			return
+		}
+		ctx := context.Background()
+		var r ExpenseReport
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			if r, err = lockDraftReport(ctx, tx, atoi(c.Param("id")), userID); err != nil {
+				return err
+			}
+			r.Title = title
+			_, err = tx.Exec(ctx, "UPDATE expense_reports SET title=$1, updated_at=now() WHERE id=$2", title, r.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update expense report")
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	})
+
+	// Delete a draft report that was never submitted
+	auth.DELETE("/claims/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			r, err := lockDraftReport(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			var submitted bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM expense_report_transitions WHERE report_id=$1)", r.ID).Scan(&submitted); err != nil {
+				return err
+			}
+			if submitted {
+				return newAPIError(http.StatusConflict, "A report that has been submitted is kept for its audit trail")
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM expense_reports WHERE id=$1", r.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to delete expense report")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Expense report deleted"})
+	})
+
+	auth.POST("/claims/:id/items", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			ExpenseID int    `json:"expense_id"`
+			Note      string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		var items []ReportItem
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			r, err := lockDraftReport(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			if err := addReportItem(ctx, tx, r.ID, input.ExpenseID, userID, input.Note); err != nil {
+				return err
+			}
+			items, err = reportItems(ctx, tx, r.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add expense to report")
+			return
+		}
+		c.JSON(http.StatusCreated, items)
+	})
+
+	auth.DELETE("/claims/:id/items/:expenseId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			r, err := lockDraftReport(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			res, err := tx.Exec(ctx, "DELETE FROM expense_report_items WHERE report_id=$1 AND expense_id=$2", r.ID, atoi(c.Param("expenseId")))
+			if err != nil {
+				return err
+			}
+			if res.RowsAffected() == 0 {
+				return newAPIError(http.StatusNotFound, "Expense not in report")
+			}
+			_, err = tx.Exec(ctx, "UPDATE expense_reports SET updated_at=now() WHERE id=$1", r.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to remove expense from report")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Expense removed from report"})
+	})
+
+	// Upload a receipt for an expense in a draft report, as multipart form field "file".
+	// JPEG, PNG, WebP and PDF files up to 5 MB are accepted.
+	auth.POST("/claims/:id/items/:expenseId/receipts", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReceiptSize+1<<20)
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A receipt file is required in the file field"})
+			return
+		}
+		if header.Size > maxReceiptSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Receipts can be at most 5 MB"})
+			return
+		}
+		f, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the receipt"})
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxReceiptSize+1))
+		f.Close()
+		if err != nil || len(data) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the receipt"})
+			return
+		}
+		if len(data) > maxReceiptSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Receipts can be at most 5 MB"})
+			return
+		}
+		// The client's content type isn't trusted; what the file looks like decides
+		contentType := http.DetectContentType(data)
+		if !receiptTypes[contentType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Receipts must be JPEG, PNG, WebP or PDF files"})
+			return
+		}
+		ctx := context.Background()
+		receipt := Receipt{ExpenseID: atoi(c.Param("expenseId")), Filename: header.Filename, ContentType: contentType, Size: len(data)}
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			r, err := lockDraftReport(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			var inReport bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM expense_report_items WHERE report_id=$1 AND expense_id=$2)", r.ID, receipt.ExpenseID).Scan(&inReport); err != nil {
+				return err
+			}
+			if !inReport {
+				return newAPIError(http.StatusNotFound, "Expense not in report")
+			}
+			return tx.QueryRow(ctx,
+				"INSERT INTO expense_report_receipts (report_id, expense_id, filename, content_type, size, data) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+				r.ID, receipt.ExpenseID, receipt.Filename, receipt.ContentType, receipt.Size, data).Scan(&receipt.ID, &receipt.UploadedAt)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to save receipt")
+			return
+		}
+		c.JSON(http.StatusCreated, receipt)
+	})
+
+	// Download a receipt; the employee and the group's approvers can
+	auth.GET("/claims/:id/receipts/:receiptId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		var filename, contentType string
+		var data []byte
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			r, _, _, err := reportAccess(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			err = tx.QueryRow(ctx, "SELECT filename, content_type, data FROM expense_report_receipts WHERE id=$1 AND report_id=$2",
+				atoi(c.Param("receiptId")), r.ID).Scan(&filename, &contentType, &data)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Receipt not found")
+			}
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+		c.Data(http.StatusOK, contentType, data)
+	})
+
+	auth.DELETE("/claims/:id/receipts/:receiptId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			r, err := lockDraftReport(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			res, err := tx.Exec(ctx, "DELETE FROM expense_report_receipts WHERE id=$1 AND report_id=$2", atoi(c.Param("receiptId")), r.ID)
+			if err != nil {
+				return err
+			}
+			if res.RowsAffected() == 0 {
+				return newAPIError(http.StatusNotFound, "Receipt not found")
+			}
+			return nil
+		})
+		if err != nil {
+			respondError(c, err, "Failed to delete receipt")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt deleted"})
+	})
+
+	// reportAction handles a status change. Every action takes an optional comment, which
+	// rejecting requires; reimbursing also takes the account_id the approver paid from.
+	type actionInput struct {
+		Comment   string `json:"comment"`
+		AccountID *int   `json:"account_id"`
+	}
+	reportAction := func(to string, byApprover bool, apply func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			userID := getUserIDFromToken(c)
+			var input actionInput
+			if c.Request.ContentLength != 0 {
+				if err := c.ShouldBindJSON(&input); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			input.Comment = strings.TrimSpace(input.Comment)
+			ctx := context.Background()
+			var r ExpenseReport
+			err := withTx(ctx, func(tx pgx.Tx) error {
+				var employee, approver bool
+				var err error
+				if r, employee, approver, err = reportAccess(ctx, tx, atoi(c.Param("id")), userID); err != nil {
+					return err
+				}
+				if byApprover && !approver {
+					return newAPIError(http.StatusForbidden, "Only the group's owner or a manager can do this, and not on their own report")
+				}
+				if !byApprover && !employee {
+					return newAPIError(http.StatusForbidden, "Only the employee who made the report can do this")
+				}
+				if err := apply(ctx, tx, &r, userID, input); err != nil {
+					return err
+				}
+				if err := transitionReport(ctx, tx, &r, to, userID, input.Comment); err != nil {
+					return err
+				}
+				r, err = scanReport(tx.QueryRow(ctx, "SELECT "+reportColumns+" WHERE r.id=$1", r.ID))
+				return err
+			})
+			if err != nil {
+				respondError(c, err, "Failed to update expense report")
+				return
+			}
+			c.JSON(http.StatusOK, r)
+		}
+	}
+	notifyEmployee := func(verb string) func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+		return func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+			msg := fmt.Sprintf("Your expense report %q (₹%.2f) was %s.", r.Title, r.Total, verb)
+			if in.Comment != "" {
+				msg += " Comment: " + in.Comment
+			}
+			return notify(ctx, tx, r.UserID, "expense_report", msg, "expense_report", r.ID)
+		}
+	}
+
+	// Submit a draft for approval. Every expense needs a receipt, and the amounts claimed are
+	// taken from the expenses as they are now.
+	auth.POST("/claims/:id/submit", reportAction(reportSubmitted, false, func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+		if r.Status != reportDraft {
+			return newAPIError(http.StatusConflict, "Only draft reports can be submitted")
+		}
+		// Lock the expenses so they can't be changed or deleted while the report is submitted
+		if _, err := tx.Exec(ctx,
+			"SELECT 1 FROM expenses e JOIN expense_report_items i ON i.expense_id=e.id WHERE i.report_id=$1 FOR UPDATE OF e", r.ID); err != nil {
+			return err
+		}
+		items, err := reportItems(ctx, tx, r.ID)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return newAPIError(http.StatusBadRequest, "Add at least one expense before submitting")
+		}
+		for _, it := range items {
+			if len(it.Receipts) == 0 {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Expense %d needs a receipt", it.ExpenseID))
+			}
+		}
+		if _, err := tx.Exec(ctx,
+			"UPDATE expense_report_items i SET amount=e.amount FROM expenses e WHERE e.id=i.expense_id AND i.report_id=$1", r.ID); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM expense_report_items WHERE report_id=$1", r.ID).Scan(&r.Total); err != nil {
+			return err
+		}
+		return notifyApprovers(ctx, tx, *r, fmt.Sprintf("%s submitted expense report %q (₹%.2f) for approval.", r.EmployeeName, r.Title, r.Total))
+	}))
+	auth.POST("/claims/:id/withdraw", reportAction(reportDraft, false, func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+		if r.Status != reportSubmitted {
+			return newAPIError(http.StatusConflict, "Only submitted reports can be withdrawn")
+		}
+		return nil
+	}))
+	auth.POST("/claims/:id/reopen", reportAction(reportDraft, false, func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+		if r.Status != reportRejected {
+			return newAPIError(http.StatusConflict, "Only rejected reports can be reopened")
+		}
+		return nil
+	}))
+	auth.POST("/claims/:id/approve", reportAction(reportApproved, true, notifyEmployee("approved")))
+	auth.POST("/claims/:id/reject", reportAction(reportRejected, true, func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+		if in.Comment == "" {
+			return newAPIError(http.StatusBadRequest, "A comment is required when rejecting a report")
+		}
+		return notifyEmployee("rejected")(ctx, tx, r, userID, in)
+	}))
+
+	// Reimburse an approved report. The approver's payment is recorded as a Payment, and the
+	// employee receives it as income.
+	auth.POST("/claims/:id/reimburse", reportAction(reportReimbursed, true, func(ctx context.Context, tx pgx.Tx, r *ExpenseReport, userID int, in actionInput) error {
+		if r.Status != reportApproved {
+			return newAPIError(http.StatusConflict, "Only approved reports can be reimbursed")
+		}
+		if err := checkAccount(ctx, tx, userID, in.AccountID); err != nil {
+			return err
+		}
+		description := fmt.Sprintf("Reimbursement: %s (%s)", r.Title, r.EmployeeName)
+		now := time.Now()
+		var paymentID int
+		err := tx.QueryRow(ctx, "INSERT INTO payments (user_id, payment_date, amount, description, account_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			userID, now, r.Total, description, in.AccountID).Scan(&paymentID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "UPDATE expense_reports SET payment_id=$1 WHERE id=$2", paymentID, r.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO incomes (user_id, date, amount, source, description) VALUES ($1, $2, $3, $4, $5)",
+			r.UserID, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), r.Total, "refund", "Reimbursement: "+r.Title); err != nil {
+			return err
+		}
+		return notifyEmployee("reimbursed")(ctx, tx, r, userID, in)
+	}))
+}
+
+// addReportItem adds one of the employee's expenses to a report. An expense can be claimed
+// only once.
+func addReportItem(ctx context.Context, tx pgx.Tx, reportID, expenseID, userID int, note string) error {
+	exp, err := lockOwnedExpense(ctx, tx, expenseID, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO expense_report_items (report_id, expense_id, amount, note) VALUES ($1, $2, $3, $4)",
+		reportID, exp.ID, exp.Amount, note)
+	if isUniqueViolation(err) {
+		return newAPIError(http.StatusConflict, "Expense is already in an expense report")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "UPDATE expense_reports SET updated_at=now() WHERE id=$1", reportID)
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds settings read from the environment (or .env) at startup
+type Config struct {
+	PaymentGateway        string // "razorpay" (default) or "fake" for offline development
+	RazorpayKeyID         string
+	RazorpayKeySecret     string
+	RazorpayWebhookSecret string
+
+	AIServiceURL      string        // base URL of the Python categorization service
+	AIServiceTimeout  time.Duration // per attempt
+	AIServiceRetries  int           // extra attempts after the first
+	AICacheTTL        time.Duration // how long AI service predictions are reused
+	AIConcurrency     int           // parallel AI service calls per batch
+	AIBreakerFailures int           // consecutive failures that open the circuit breaker
+	AIBreakerCooldown time.Duration // how long the breaker stays open before trying again
+
+	ChatbotLLM        string // chatbot fallback for questions without an intent: "" (none) or "huggingface"
+	ChatbotLLMURL     string
+	ChatbotLLMToken   string
+	ChatbotLLMTimeout time.Duration
+}
+
+var cfg Config
+
+func loadConfig() Config {
+	c := Config{
+		PaymentGateway:        os.Getenv("PAYMENT_GATEWAY"),
+		RazorpayKeyID:         os.Getenv("RAZORPAY_KEY_ID"),
+		RazorpayKeySecret:     os.Getenv("RAZORPAY_KEY_SECRET"),
+		RazorpayWebhookSecret: os.Getenv("RAZORPAY_WEBHOOK_SECRET"),
+
+		AIServiceURL:      envOr("AI_SERVICE_URL", "http://localhost:8001"),
+		AIServiceTimeout:  envDuration("AI_SERVICE_TIMEOUT", 5*time.Second),
+		AIServiceRetries:  envInt("AI_SERVICE_RETRIES", 2),
+		AICacheTTL:        envDuration("AI_CACHE_TTL", time.Hour),
+		AIConcurrency:     envInt("AI_CONCURRENCY", 8),
+		AIBreakerFailures: envInt("AI_BREAKER_FAILURES", 5),
+		AIBreakerCooldown: envDuration("AI_BREAKER_COOLDOWN", 30*time.Second),
+
+		ChatbotLLM:        os.Getenv("CHATBOT_LLM"),
+		ChatbotLLMURL:     envOr("CHATBOT_LLM_URL", "https://api-inference.huggingface.co/models/google/flan-t5-large"),
+		ChatbotLLMToken:   os.Getenv("CHATBOT_LLM_TOKEN"),
+		ChatbotLLMTimeout: envDuration("CHATBOT_LLM_TIMEOUT", 30*time.Second),
+	}
+	return c
+}
+
+// envOr returns the environment variable key, or def when it is unset
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt parses an integer environment variable, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		fmt.Printf("[CONFIG] Invalid %s=%q, using %d\n", key, v, def)
+		return def
+	}
+	return n
+}
+
+// envDuration parses a duration such as "5s" or "500ms", falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fmt.Printf("[CONFIG] Invalid %s=%q, using %s\n", key, v, def)
+		return def
+	}
+	return d
+}
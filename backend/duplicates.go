@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Expenses within this many days of each other can be duplicates
+	duplicateDateWindowDays = 3
+	// Amounts may differ by this fraction (rounding, convenience fees) and still match
+	duplicateAmountTolerance = 0.01
+	// Minimum description similarity when both expenses have a description
+	duplicateMinDescriptionSimilarity = 0.5
+)
+
+// duplicateCandidate is an existing expense that looks like the same bill
+type duplicateCandidate struct {
+	Expense Expense `json:"expense"`
+	Score   float64 `json:"score"`
+}
+
+// findDuplicateExpenses returns the user's expenses that likely duplicate exp, best match first.
+// excludeID skips a specific expense (e.g. the one being edited); pass 0 to check against all.
+func findDuplicateExpenses(ctx context.Context, q querier, userID int, exp Expense, excludeID int) ([]duplicateCandidate, error) {
+	tolerance := math.Max(math.Abs(exp.Amount)*duplicateAmountTolerance, 0.01)
+	from := exp.Date.AddDate(0, 0, -duplicateDateWindowDays)
+	to := exp.Date.AddDate(0, 0, duplicateDateWindowDays)
+	rows, err := q.Query(ctx,
+		"SELECT "+expenseColumns+" FROM expenses WHERE user_id=$1 AND id<>$2 AND ABS(amount - $3) <= $4 AND date BETWEEN $5 AND $6",
+		userID, excludeID, exp.Amount, tolerance, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var existing []Expense
+	for rows.Next() {
+		e, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		existing = append(existing, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return matchDuplicates(exp, existing), nil
+}
+
+// matchDuplicates scores each candidate against exp and keeps the likely duplicates, best first
+func matchDuplicates(exp Expense, candidates []Expense) []duplicateCandidate {
+	matches := make([]duplicateCandidate, 0)
+	for _, cand := range candidates {
+		if score, ok := duplicateScore(exp, cand); ok {
+			matches = append(matches, duplicateCandidate{Expense: cand, Score: score})
+		}
+	}
+	// Insertion sort, candidate lists are tiny
+	for i := 1; i < len(matches); i++ {
+		for j := i; j > 0 && matches[j].Score > matches[j-1].Score; j-- {
+			matches[j], matches[j-1] = matches[j-1], matches[j]
+		}
+	}
+	return matches
+}
+
+// duplicateScore rates how likely a and b are the same bill, from 0 to 1.
+// ok is false when they differ too much in amount, date or description to be duplicates.
+func duplicateScore(a, b Expense) (score float64, ok bool) {
+	tolerance := math.Max(math.Abs(a.Amount)*duplicateAmountTolerance, 0.01)
+	if math.Abs(a.Amount-b.Amount) > tolerance {
+		return 0, false
+	}
+	days := math.Abs(dayOf(a.Date).Sub(dayOf(b.Date)).Hours() / 24)
+	if days > duplicateDateWindowDays {
+		return 0, false
+	}
+	// An empty description (typical of quick manual entries) neither confirms nor rules out a match
+	descSim := 0.5
+	if strings.TrimSpace(a.Description) != "" && strings.TrimSpace(b.Description) != "" {
+		descSim = descriptionSimilarity(a.Description, b.Description)
+		if descSim < duplicateMinDescriptionSimilarity {
+			return 0, false
+		}
+	}
+	dateScore := 1 - days/float64(duplicateDateWindowDays+1)
+	score = 0.4 + 0.3*dateScore + 0.3*descSim
+	return math.Round(score*100) / 100, true
+}
+
+// dayOf truncates t to midnight so date proximity ignores the time component
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// normalizeDescription lowercases s and reduces it to space separated alphanumeric words
+func normalizeDescription(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// descriptionSimilarity compares two descriptions, returning 1 for identical text and 0 for nothing in common.
+// It takes the better of word overlap (robust to reordering) and edit distance (robust to typos).
+func descriptionSimilarity(a, b string) float64 {
+	na, nb := normalizeDescription(a), normalizeDescription(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+	wordsA := strings.Fields(na)
+	wordsB := strings.Fields(nb)
+	set := make(map[string]bool, len(wordsA))
+	for _, w := range wordsA {
+		set[w] = true
+	}
+	common := 0
+	union := len(set)
+	seen := make(map[string]bool, len(wordsB))
+	for _, w := range wordsB {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			common++
+		} else {
+			union++
+		}
+	}
+	jaccard := float64(common) / float64(union)
+
+	ra, rb := []rune(na), []rune(nb)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	editSim := 1 - float64(levenshtein(ra, rb))/float64(longest)
+	return math.Max(jaccard, editSim)
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func registerDuplicateRoutes(auth *gin.RouterGroup) {
+	// Bulk import expenses. Likely duplicates (of existing expenses or of earlier rows
+	// in the same import) are skipped and reported unless ?force=true is passed.
+	auth.POST("/expenses/import", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var inputs []expenseInput
+		if err := c.ShouldBindJSON(&inputs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		force := c.Query("force") == "true"
+		type rowDuplicate struct {
+			Index      int                  `json:"index"`
+			Row        expenseInput         `json:"row"`
+			Duplicates []duplicateCandidate `json:"duplicates"`
+		}
+		type rowError struct {
+			Index int    `json:"index"`
+			Error string `json:"error"`
+		}
+		imported := make([]Expense, 0, len(inputs))
+		skipped := make([]rowDuplicate, 0)
+		errs := make([]rowError, 0)
+		// Rows are validated and categorized up front so the transaction is not held
+		// open while the AI service is called. Rows without a category are categorized
+		// as one batch.
+		ctx := context.Background()
+		exps := make([]*Expense, len(inputs))
+		var uncategorized []int
+		for i, in := range inputs {
+			exp, err := in.toExpense()
+			if err != nil {
+				errs = append(errs, rowError{Index: i, Error: err.Error()})
+				continue
+			}
+			exps[i] = &exp
+			if strings.TrimSpace(exp.Category) == "" && strings.TrimSpace(exp.Description) != "" {
+				uncategorized = append(uncategorized, i)
+			} else if err := categorizeExpense(ctx, userID, &exp); err != nil {
+				errs = append(errs, rowError{Index: i, Error: err.Error()})
+				exps[i] = nil
+			}
+		}
+		if len(uncategorized) > 0 {
+			items := make([]batchItem, len(uncategorized))
+			for j, i := range uncategorized {
+				items[j] = batchItem{Description: exps[i].Description, Amount: &exps[i].Amount}
+			}
+			results, err := categorizeBatch(c.Request.Context(), userID, items)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to categorize expenses"})
+				return
+			}
+			for j, i := range uncategorized {
+				p := categoryPrediction{Category: results[j].Category, Confidence: results[j].Confidence, Source: results[j].Source}
+				if results[j].Error != "" {
+					p = categoryPrediction{Category: "Other", Source: sourceFallback}
+				}
+				if p, err = resolvePrediction(ctx, userID, p); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to categorize expenses"})
+					return
+				}
+				exps[i].Category, exps[i].CategoryConfidence, exps[i].CategorySource = p.Category, p.Confidence, p.Source
+			}
+		}
+		// The import is one transaction; each row gets a savepoint so a bad row
+		// is reported without discarding the rest. Earlier rows are visible to
+		// the duplicate check of later ones.
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			for i, in := range inputs {
+				exp := exps[i]
+				if exp == nil {
+					continue
+				}
+				if !force {
+					dups, err := findDuplicateExpenses(ctx, tx, userID, *exp, 0)
+					if err != nil {
+						return err
+					}
+					if len(dups) > 0 {
+						skipped = append(skipped, rowDuplicate{Index: i, Row: in, Duplicates: dups})
+						continue
+					}
+				}
+				sp, err := tx.Begin(ctx)
+				if err != nil {
+					return err
+				}
+				if err := insertExpense(ctx, sp, userID, exp); err != nil {
+					sp.Rollback(ctx)
+					errs = append(errs, rowError{Index: i, Error: "Failed to add expense"})
+					continue
+				}
+				if err := sp.Commit(ctx); err != nil {
+					return err
+				}
+				imported = append(imported, *exp)
+			}
+			return nil
+		})
+		if err != nil {
+			respondError(c, err, "Failed to import expenses")
+			return
+		}
+		for _, exp := range imported {
+			checkAnomalies(ctx, userID, expenseSubject(exp))
+		}
+		c.JSON(http.StatusOK, gin.H{"imported": imported, "duplicates": skipped, "errors": errs})
+	})
+
+	// List likely duplicates of an existing expense
+	auth.GET("/expenses/:id/duplicates", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		id := atoi(c.Param("id"))
+		exp, err := scanExpense(db.QueryRow(context.Background(),
+			"SELECT "+expenseColumns+" FROM expenses WHERE id=$1 AND user_id=$2", id, userID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+		dups, err := findDuplicateExpenses(context.Background(), db, userID, exp, exp.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates"})
+			return
+		}
+		c.JSON(http.StatusOK, dups)
+	})
+
+	// Merge a duplicate into this expense: payments and orders linked to the duplicate are re-linked,
+	// missing fields are filled from the duplicate, and the duplicate is deleted. Duplicates
+	// claimed in a submitted report or split with others can't be merged.
+	auth.POST("/expenses/:id/merge", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		keepID := atoi(c.Param("id"))
+		var req struct {
+			DuplicateID int `json:"duplicate_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.DuplicateID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duplicate_id required"})
+			return
+		}
+		if req.DuplicateID == keepID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge an expense into itself"})
+			return
+		}
+		var keep Expense
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			keep, err = lockOwnedExpense(ctx, tx, keepID, userID)
+			if err != nil {
+				return err
+			}
+			dup, err := lockOwnedExpense(ctx, tx, req.DuplicateID, userID)
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				return newAPIError(http.StatusNotFound, "Duplicate expense not found")
+			}
+			if err != nil {
+				return err
+			}
+			// Deleting the duplicate would drop it from a submitted report, or its split from the
+			// group's balances
+			claimed, err := expenseClaimed(ctx, tx, dup.ID)
+			if err != nil {
+				return err
+			}
+			if claimed {
+				return newAPIError(http.StatusConflict, "Duplicate expense is in a submitted expense report")
+			}
+			var split bool
+			if err := tx.QueryRow(ctx, "SELECT split_method IS NOT NULL FROM expenses WHERE id=$1", dup.ID).Scan(&split); err != nil {
+				return err
+			}
+			if split || dup.GroupID != nil {
+				return newAPIError(http.StatusConflict, "Duplicate expense is shared; remove its split before merging")
+			}
+
+			if keep.Category == "" {
+				keep.Category, keep.CategoryConfidence, keep.CategorySource = dup.Category, dup.CategoryConfidence, dup.CategorySource
+			}
+			if keep.Description == "" {
+				keep.Description = dup.Description
+			}
+			if keep.AccountID == nil {
+				keep.AccountID = dup.AccountID
+			}
+			if dup.Paid && !keep.Paid {
+				keep.Paid = true
+				keep.PaymentStatus = dup.PaymentStatus
+			}
+
+			if _, err := tx.Exec(ctx, "UPDATE payments SET expense_id=$1 WHERE expense_id=$2 AND user_id=$3", keep.ID, dup.ID, userID); err != nil {
+				return err
+			}
+			// An open checkout for the duplicate pays the kept expense instead
+			if _, err := tx.Exec(ctx, "UPDATE payment_orders SET expense_id=$1 WHERE expense_id=$2 AND user_id=$3", keep.ID, dup.ID, userID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx,
+				"UPDATE expenses SET category=$1, description=$2, paid=$3, payment_status=$4, category_confidence=$5, category_source=$6, account_id=$7 WHERE id=$8 AND user_id=$9",
+				keep.Category, keep.Description, keep.Paid, keep.PaymentStatus, keep.CategoryConfidence, keep.CategorySource, keep.AccountID, keep.ID, userID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx,
+				"INSERT INTO expense_tags (expense_id, tag_id) SELECT $1, tag_id FROM expense_tags WHERE expense_id=$2 ON CONFLICT DO NOTHING", keep.ID, dup.ID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "DELETE FROM expenses WHERE id=$1 AND user_id=$2", dup.ID, userID); err != nil {
+				return err
+			}
+			// The kept expense may now carry both sets of payments
+			if keep.PaidAmount+dup.PaidAmount > 0 {
+				keep, err = reconcileExpense(ctx, tx, keep.ID, userID)
+			} else {
+				keep, err = lockOwnedExpense(ctx, tx, keep.ID, userID) // reload merged tags
+			}
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to merge expenses")
+			return
+		}
+		c.JSON(http.StatusOK, keep)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"swiggy", "swiggy", 0},
+		{"swiggy", "swigy", 1},
+		{"kitten", "sitting", 3},
+		{"zomato", "tomato", 1},
+		{"₹500", "₹50", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateScore(t *testing.T) {
+	day := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	base := Expense{Date: day, Amount: 500, Description: "Dominos pizza"}
+	tests := []struct {
+		name      string
+		other     Expense
+		wantScore float64
+		wantOK    bool
+	}{
+		{"identical", Expense{Date: day, Amount: 500, Description: "Dominos pizza"}, 1, true},
+		{"time of day ignored", Expense{Date: day.Add(15 * time.Hour), Amount: 500, Description: "Dominos pizza"}, 1, true},
+		{"two days apart", Expense{Date: day.AddDate(0, 0, 2), Amount: 500, Description: "Dominos pizza"}, 0.85, true},
+		{"within amount tolerance", Expense{Date: day, Amount: 504, Description: "dominos  PIZZA!"}, 1, true},
+		{"amount too different", Expense{Date: day, Amount: 510, Description: "Dominos pizza"}, 0, false},
+		{"outside date window", Expense{Date: day.AddDate(0, 0, -4), Amount: 500, Description: "Dominos pizza"}, 0, false},
+		{"empty description is neutral", Expense{Date: day, Amount: 500}, 0.85, true},
+		{"unrelated description", Expense{Date: day, Amount: 500, Description: "Electricity bill"}, 0, false},
+		{"reordered words", Expense{Date: day, Amount: 500, Description: "pizza dominos"}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, ok := duplicateScore(base, tt.other)
+			if ok != tt.wantOK || score != tt.wantScore {
+				t.Errorf("duplicateScore = (%v, %v), want (%v, %v)", score, ok, tt.wantScore, tt.wantOK)
+			}
+		})
+	}
+}
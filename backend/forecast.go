@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Forecast settings. Discretionary spending per category is projected from its monthly mean over
+// the history window; recurring items are expected once a month at their median amount.
+const (
+	forecastHistoryMonths   = 6
+	forecastRecurringMonths = 3     // months a merchant must appear in, within the history, to count as recurring
+	forecastMaxMonths       = 12    // furthest ahead a forecast may look
+	forecastZ               = 1.645 // 90% interval
+)
+
+// categoryForecast is the projected spending of one category in a month
+type categoryForecast struct {
+	Category  string  `json:"category"`
+	Spent     float64 `json:"spent"` // dated in the month up to today
+	Projected float64 `json:"projected"`
+	Low       float64 `json:"low"`
+	High      float64 `json:"high"`
+}
+
+// monthForecast is the projected spending of a month, the current one included
+type monthForecast struct {
+	Month      string             `json:"month"` // YYYY-MM
+	Spent      float64            `json:"spent"`
+	Scheduled  float64            `json:"scheduled"` // expenses already entered with a date after today
+	Recurring  float64            `json:"recurring"` // recurring items expected but not yet entered
+	Projected  float64            `json:"projected"`
+	Low        float64            `json:"low"`
+	High       float64            `json:"high"`
+	Budget     float64            `json:"budget"`
+	Status     string             `json:"status,omitempty"` // ok, at_risk, likely_over or over; empty without a budget
+	ByCategory []categoryForecast `json:"by_category"`
+}
+
+// recurringItem is a merchant the user pays in most months
+type recurringItem struct {
+	Category   string  `json:"category"`
+	Merchant   string  `json:"merchant"`
+	Amount     float64 `json:"amount"` // median monthly amount
+	MonthsSeen int     `json:"months_seen"`
+	LastDate   string  `json:"last_date"`
+}
+
+// dueBill is an expense or credit card statement that is not fully paid
+type dueBill struct {
+	ExpenseID   int     `json:"expense_id,omitempty"`
+	StatementID int     `json:"statement_id,omitempty"`
+	Date        string  `json:"date"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	Outstanding float64 `json:"outstanding"`
+	Overdue     bool    `json:"overdue"`
+}
+
+// meanStd is the mean and sample standard deviation of xs
+func meanStd(xs []float64) (mean, std float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		std += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(xs)-1))
+}
+
+// budgetStatus compares a month's projection with the monthly budget
+func budgetStatus(m monthForecast) string {
+	switch {
+	case m.Budget <= 0:
+		return ""
+	case m.Spent > m.Budget:
+		return "over"
+	case m.Projected > m.Budget:
+		return "likely_over"
+	case m.High > m.Budget:
+		return "at_risk"
+	}
+	return "ok"
+}
+
+// forecastSpending projects the current month and the next `months` months from expenses dated
+// between the start of the history window and the end of the forecast. It also returns the
+// recurring items it found in the history.
+func forecastSpending(expenses []Expense, now time.Time, months int, budget float64) ([]monthForecast, []recurringItem) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	thisMonth := monthStart(today)
+	histStart := thisMonth.AddDate(0, -forecastHistoryMonths, 0)
+	const cur = forecastHistoryMonths // index of the current month
+	monthIndex := func(t time.Time) int {
+		return (t.Year()-histStart.Year())*12 + int(t.Month()) - int(histStart.Month())
+	}
+	itemKey := func(e Expense) string {
+		if key := merchantKey(e.Description); key != "" {
+			return e.Category + "\x00" + key
+		}
+		return ""
+	}
+
+	// Find recurring merchants: present in enough history months and seen recently
+	type series struct {
+		category, merchant string
+		monthly            map[int]float64
+		last               time.Time
+	}
+	seriesByKey := make(map[string]*series)
+	first := -1 // earliest history month with any expense
+	for _, e := range expenses {
+		i := monthIndex(e.Date)
+		if i < 0 || i >= cur {
+			continue
+		}
+		if first < 0 || i < first {
+			first = i
+		}
+		key := itemKey(e)
+		if key == "" {
+			continue
+		}
+		s := seriesByKey[key]
+		if s == nil {
+			s = &series{category: e.Category, merchant: merchantKey(e.Description), monthly: make(map[int]float64)}
+			seriesByKey[key] = s
+		}
+		s.monthly[i] += e.Amount
+		if e.Date.After(s.last) {
+			s.last = e.Date
+		}
+	}
+	recurring := make([]recurringItem, 0)
+	recurringAmount := make(map[string]float64)
+	recurringVariance := make(map[string]float64)
+	for key, s := range seriesByKey {
+		if len(s.monthly) < forecastRecurringMonths || monthIndex(s.last) < cur-2 {
+			continue
+		}
+		amounts := make([]float64, 0, len(s.monthly))
+		for _, a := range s.monthly {
+			amounts = append(amounts, a)
+		}
+		sort.Float64s(amounts)
+		amount := roundMoney(median(amounts))
+		recurringAmount[key] = amount
+		_, std := meanStd(amounts)
+		recurringVariance[key] = std * std
+		recurring = append(recurring, recurringItem{
+			Category: s.category, Merchant: s.merchant, Amount: amount, MonthsSeen: len(s.monthly), LastDate: s.last.Format("2006-01-02"),
+		})
+	}
+	sort.Slice(recurring, func(i, j int) bool {
+		if recurring[i].Category != recurring[j].Category {
+			return recurring[i].Category < recurring[j].Category
+		}
+		return recurring[i].Merchant < recurring[j].Merchant
+	})
+
+	// Discretionary (non-recurring) monthly totals per category, from the first month with data
+	historyMonths := 0
+	if first >= 0 {
+		historyMonths = cur - first
+	}
+	discretionary := make(map[string][]float64)
+	for _, e := range expenses {
+		i := monthIndex(e.Date)
+		if i < 0 || i >= cur {
+			continue
+		}
+		if _, ok := recurringAmount[itemKey(e)]; ok {
+			continue
+		}
+		if discretionary[e.Category] == nil {
+			discretionary[e.Category] = make([]float64, historyMonths)
+		}
+		discretionary[e.Category][i-first] += e.Amount
+	}
+	type baseline struct{ mean, std float64 }
+	baselines := make(map[string]baseline)
+	for category, totals := range discretionary {
+		mean, std := meanStd(totals)
+		baselines[category] = baseline{mean, std}
+	}
+
+	// Without any history, extrapolate this month's run rate with a wide interval
+	daysInMonth := float64(thisMonth.AddDate(0, 1, -1).Day())
+	if historyMonths == 0 {
+		runRate := make(map[string]float64)
+		for _, e := range expenses {
+			if monthIndex(e.Date) == cur && !e.Date.After(today) {
+				runRate[e.Category] += e.Amount
+			}
+		}
+		for category, spent := range runRate {
+			mean := spent / float64(today.Day()) * daysInMonth
+			baselines[category] = baseline{mean, mean / 2}
+		}
+	}
+
+	forecasts := make([]monthForecast, 0, months+1)
+	for i := cur; i <= cur+months; i++ {
+		start := histStart.AddDate(0, i, 0)
+		remaining := 1.0 // share of the month still to come
+		if i == cur {
+			remaining = (daysInMonth - float64(today.Day())) / daysInMonth
+		}
+		// scheduled amounts are certain; expected recurring items and the discretionary estimate are not
+		type acc struct{ spent, scheduled, expected, estimate, variance float64 }
+		byCategory := make(map[string]*acc)
+		get := func(category string) *acc {
+			if byCategory[category] == nil {
+				byCategory[category] = &acc{}
+			}
+			return byCategory[category]
+		}
+		m := monthForecast{Month: start.Format("2006-01"), Budget: budget}
+		seen := make(map[string]bool)
+		for _, e := range expenses {
+			if monthIndex(e.Date) != i {
+				continue
+			}
+			seen[itemKey(e)] = true
+			a := get(e.Category)
+			if e.Date.After(today) {
+				a.scheduled += e.Amount
+				m.Scheduled += e.Amount
+			} else {
+				a.spent += e.Amount
+				m.Spent += e.Amount
+			}
+		}
+		for key, amount := range recurringAmount {
+			if !seen[key] {
+				a := get(seriesByKey[key].category)
+				a.expected += amount
+				a.variance += recurringVariance[key]
+				m.Recurring += amount
+			}
+		}
+		for category, b := range baselines {
+			a := get(category)
+			a.estimate = b.mean * remaining
+			a.variance += b.std * b.std * remaining
+		}
+
+		var floor, variance float64
+		for category, a := range byCategory {
+			certain := a.spent + a.scheduled
+			projected := certain + a.expected + a.estimate
+			half := forecastZ * math.Sqrt(a.variance)
+			m.ByCategory = append(m.ByCategory, categoryForecast{
+				Category: category, Spent: roundMoney(a.spent), Projected: roundMoney(projected),
+				Low: roundMoney(math.Max(certain, projected-half)), High: roundMoney(projected + half),
+			})
+			m.Projected += projected
+			floor += certain
+			variance += a.variance
+		}
+		half := forecastZ * math.Sqrt(variance)
+		m.Low = roundMoney(math.Max(floor, m.Projected-half))
+		m.High = roundMoney(m.Projected + half)
+		m.Projected = roundMoney(m.Projected)
+		m.Spent = roundMoney(m.Spent)
+		m.Scheduled = roundMoney(m.Scheduled)
+		m.Recurring = roundMoney(m.Recurring)
+		m.Status = budgetStatus(m)
+		if m.ByCategory == nil {
+			m.ByCategory = make([]categoryForecast, 0)
+		}
+		sort.Slice(m.ByCategory, func(i, j int) bool {
+			if m.ByCategory[i].Projected != m.ByCategory[j].Projected {
+				return m.ByCategory[i].Projected > m.ByCategory[j].Projected
+			}
+			return m.ByCategory[i].Category < m.ByCategory[j].Category
+		})
+		forecasts = append(forecasts, m)
+	}
+	return forecasts, recurring
+}
+
+// forecastWarnings describes the months projected to exceed or risk exceeding the budget
+func forecastWarnings(forecasts []monthForecast) []string {
+	warnings := make([]string, 0)
+	for _, m := range forecasts {
+		switch m.Status {
+		case "over":
+			warnings = append(warnings, fmt.Sprintf("%s: already ₹%.2f over the budget of ₹%.2f.", m.Month, m.Spent-m.Budget, m.Budget))
+		case "likely_over":
+			warnings = append(warnings, fmt.Sprintf("%s: projected spend of ₹%.2f exceeds the budget of ₹%.2f.", m.Month, m.Projected, m.Budget))
+		case "at_risk":
+			warnings = append(warnings, fmt.Sprintf("%s: spend could reach ₹%.2f, above the budget of ₹%.2f.", m.Month, m.High, m.Budget))
+		}
+	}
+	return warnings
+}
+
+func registerForecastRoutes(auth *gin.RouterGroup) {
+	// Projected spending for the rest of this month and the next ?months= months (default 3),
+	// with 90% intervals, recurring items, unpaid bills and budget warnings
+	auth.GET("/analytics/forecast", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		months := 3
+		if v := c.Query("months"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > forecastMaxMonths {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("months must be between 0 and %d", forecastMaxMonths)})
+				return
+			}
+			months = n
+		}
+		ctx := context.Background()
+		now := time.Now()
+		thisMonth := monthStart(now)
+		histStart := thisMonth.AddDate(0, -forecastHistoryMonths, 0)
+		horizonEnd := thisMonth.AddDate(0, months+1, 0)
+
+		var budget float64
+		if err := db.QueryRow(ctx, "SELECT COALESCE(budget, 0) FROM users WHERE id=$1", userID).Scan(&budget); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		rows, err := db.Query(ctx,
+			"SELECT "+expenseColumns+" FROM expenses WHERE user_id=$1 AND date >= $2 AND date < $3 ORDER BY date, id",
+			userID, histStart, horizonEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		var expenses []Expense
+		for rows.Next() {
+			if e, err := scanExpense(rows); err == nil {
+				expenses = append(expenses, e)
+			}
+		}
+		rows.Close()
+
+		// Unpaid bills falling due before the end of the forecast, however old. Expenses on a card
+		// with a statement cycle are billed through its statements instead.
+		rows, err = db.Query(ctx,
+			"SELECT "+expenseColumns+" FROM expenses WHERE user_id=$1 AND NOT paid AND date < $2 "+
+				"AND NOT EXISTS (SELECT 1 FROM accounts a WHERE a.id=expenses.account_id AND a.type='credit_card' AND a.statement_day IS NOT NULL) "+
+				"ORDER BY date, id", userID, horizonEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		bills := make([]dueBill, 0)
+		var dueTotal float64
+		for rows.Next() {
+			e, err := scanExpense(rows)
+			if err != nil || e.Outstanding() <= 0 {
+				continue
+			}
+			bills = append(bills, dueBill{
+				ExpenseID: e.ID, Date: e.Date.Format("2006-01-02"), Description: e.Description, Category: e.Category,
+				Outstanding: e.Outstanding(), Overdue: e.Date.Before(today),
+			})
+			dueTotal += e.Outstanding()
+		}
+		rows.Close()
+		if err := syncUserStatements(ctx, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update statements"})
+			return
+		}
+		rows, err = db.Query(ctx,
+			"SELECT s.id, s.account_id, s.period_start, s.period_end, s.due_date, s.total, s.paid_amount, s.status, a.name FROM card_statements s "+
+				"JOIN accounts a ON a.id=s.account_id WHERE s.user_id=$1 AND s.paid_amount < s.total AND s.due_date < $2", userID, horizonEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		for rows.Next() {
+			var st CardStatement
+			var card string
+			if err := rows.Scan(&st.ID, &st.AccountID, &st.PeriodStart, &st.PeriodEnd, &st.DueDate, &st.Total, &st.PaidAmount, &st.Status, &card); err != nil || st.Outstanding() <= 0 {
+				continue
+			}
+			bills = append(bills, dueBill{
+				StatementID: st.ID, Date: st.DueDate.Format("2006-01-02"), Description: card + " statement",
+				Outstanding: st.Outstanding(), Overdue: st.DueDate.Before(today),
+			})
+			dueTotal += st.Outstanding()
+		}
+		rows.Close()
+		sort.SliceStable(bills, func(i, j int) bool { return bills[i].Date < bills[j].Date })
+
+		forecasts, recurring := forecastSpending(expenses, now, months, budget)
+		c.JSON(http.StatusOK, gin.H{
+			"as_of":      today.Format("2006-01-02"),
+			"confidence": 0.9,
+			"months":     forecasts,
+			"recurring":  recurring,
+			"due_bills":  bills,
+			"due_total":  roundMoney(dueTotal),
+			"warnings":   forecastWarnings(forecasts),
+		})
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetStatus(t *testing.T) {
+	tests := []struct {
+		m    monthForecast
+		want string
+	}{
+		{monthForecast{Spent: 500, Projected: 900, High: 1200}, ""},
+		{monthForecast{Budget: 1000, Spent: 1100, Projected: 1500, High: 1600}, "over"},
+		{monthForecast{Budget: 1000, Spent: 500, Projected: 1100, High: 1300}, "likely_over"},
+		{monthForecast{Budget: 1000, Spent: 500, Projected: 900, High: 1100}, "at_risk"},
+		{monthForecast{Budget: 1000, Spent: 500, Projected: 800, High: 950}, "ok"},
+	}
+	for _, tt := range tests {
+		if got := budgetStatus(tt.m); got != tt.want {
+			t.Errorf("budgetStatus(%+v) = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestForecastSpending(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC) }
+	now := day(time.October, 15).Add(9 * time.Hour)
+	expenses := []Expense{
+		{Date: day(time.July, 1), Category: "Housing", Amount: 10000, Description: "Landlord rent"},
+		{Date: day(time.August, 1), Category: "Housing", Amount: 10000, Description: "Landlord rent"},
+		{Date: day(time.September, 1), Category: "Housing", Amount: 10000, Description: "Landlord rent"},
+		{Date: day(time.November, 1), Category: "Housing", Amount: 10000, Description: "Landlord rent"},
+		{Date: day(time.July, 12), Category: "Food", Amount: 3000},
+		{Date: day(time.August, 12), Category: "Food", Amount: 3000},
+		{Date: day(time.September, 12), Category: "Food", Amount: 3000},
+		{Date: day(time.October, 5), Category: "Food", Amount: 1000},
+		{Date: day(time.March, 5), Category: "Food", Amount: 99999}, // before the history window
+	}
+	forecasts, recurring := forecastSpending(expenses, now, 1, 12000)
+
+	if len(recurring) != 1 || recurring[0] != (recurringItem{Category: "Housing", Merchant: "landlord rent", Amount: 10000, MonthsSeen: 3, LastDate: "2026-09-01"}) {
+		t.Errorf("recurring = %+v", recurring)
+	}
+	if len(forecasts) != 2 {
+		t.Fatalf("got %d forecasts, want 2", len(forecasts))
+	}
+	tests := []struct {
+		month                                  string
+		spent, scheduled, recurring, projected float64
+		status                                 string
+	}{
+		// Rent is still expected; the rest of Food's 3000 a month is prorated over the 16 days left
+		{"2026-10", 1000, 0, 10000, 12548.39, "likely_over"},
+		// Rent is already entered for November, so it is scheduled rather than expected
+		{"2026-11", 0, 10000, 0, 13000, "likely_over"},
+	}
+	for i, tt := range tests {
+		m := forecasts[i]
+		if m.Month != tt.month || m.Spent != tt.spent || m.Scheduled != tt.scheduled || m.Recurring != tt.recurring ||
+			m.Projected != tt.projected || m.Status != tt.status {
+			t.Errorf("forecast %d = %+v, want %+v", i, m, tt)
+		}
+		// Both series are constant, so there is no uncertainty
+		if m.Low != m.Projected || m.High != m.Projected {
+			t.Errorf("%s: interval [%v, %v], want %v", m.Month, m.Low, m.High, m.Projected)
+		}
+		if len(m.ByCategory) != 2 || m.ByCategory[0].Category != "Housing" {
+			t.Errorf("%s: by category = %+v", m.Month, m.ByCategory)
+		}
+	}
+}
+
+func TestForecastSpendingWithoutHistory(t *testing.T) {
+	now := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	expenses := []Expense{{Date: time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC), Category: "Food", Amount: 1500}}
+	forecasts, recurring := forecastSpending(expenses, now, 0, 0)
+	if len(recurring) != 0 || len(forecasts) != 1 {
+		t.Fatalf("got %d forecasts and %d recurring items", len(forecasts), len(recurring))
+	}
+	// 1500 in 15 days runs to 3100 over the 31 days of October
+	m := forecasts[0]
+	if m.Spent != 1500 || m.Projected != 3100 || m.Status != "" {
+		t.Errorf("forecast = %+v", m)
+	}
+	if m.Low < m.Spent || m.Low >= m.Projected || m.High <= m.Projected {
+		t.Errorf("interval [%v, %v] around %v", m.Low, m.High, m.Projected)
+	}
+}
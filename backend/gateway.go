@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GatewayOrder is an order created with a payment gateway
+type GatewayOrder struct {
+	ID       string `json:"id"`
+	Amount   int64  `json:"amount"` // paise
+	Currency string `json:"currency"`
+	Receipt  string `json:"receipt"`
+	Status   string `json:"status"`
+}
+
+// GatewayPayment is a payment as reported by the gateway
+type GatewayPayment struct {
+	ID        string
+	OrderID   string
+	Amount    int64 // paise
+	Currency  string
+	Status    string // created, authorized, captured, refunded, failed
+	CreatedAt time.Time
+}
+
+// GatewayRefund is a refund issued through the gateway
+type GatewayRefund struct {
+	ID        string
+	PaymentID string
+	Amount    int64  // paise
+	Status    string // pending, processed, failed
+}
+
+// PaymentGateway is the checkout provider. Razorpay is used in production;
+// the fake gateway runs the same flow in-process for local development.
+type PaymentGateway interface {
+	// Name identifies the gateway in logs and API responses
+	Name() string
+	// KeyID is the public key the frontend passes to checkout
+	KeyID() string
+	CreateOrder(amount int64, currency, receipt string, notes map[string]string) (GatewayOrder, error)
+	// VerifySignature checks the signature returned to the browser after checkout
+	VerifySignature(orderID, paymentID, signature string) bool
+	// VerifyWebhookSignature checks the signature header sent with a webhook body
+	VerifyWebhookSignature(body []byte, signature string) bool
+	FetchPayment(paymentID string) (GatewayPayment, error)
+	// Refund refunds amount paise of a captured payment; 0 refunds the remaining balance
+	Refund(paymentID string, amount int64) (GatewayRefund, error)
+}
+
+// gateway is the configured payment gateway, nil when none is configured
+var gateway PaymentGateway
+
+// newPaymentGateway builds the gateway selected by PAYMENT_GATEWAY
+func newPaymentGateway(c Config) (PaymentGateway, error) {
+	switch c.PaymentGateway {
+	case "", "razorpay":
+		if c.RazorpayKeyID == "" || c.RazorpayKeySecret == "" {
+			return nil, errors.New("RAZORPAY_KEY_ID/RAZORPAY_KEY_SECRET not set")
+		}
+		return newRazorpayGateway(c.RazorpayKeyID, c.RazorpayKeySecret, c.RazorpayWebhookSecret), nil
+	case "fake":
+		return newFakeGateway(), nil
+	}
+	return nil, fmt.Errorf("unknown PAYMENT_GATEWAY %q", c.PaymentGateway)
+}
+
+// fakeGatewaySecret signs fake checkout responses and webhooks, like a Razorpay key secret
+const fakeGatewaySecret = "fake_gateway_secret"
+
+// fakeGateway is an in-memory gateway for local development and offline integration runs.
+// Orders are paid with Capture, which returns what Razorpay checkout would hand the browser.
+type fakeGateway struct {
+	mu       sync.Mutex
+	orders   map[string]GatewayOrder
+	payments map[string]GatewayPayment
+	refunded map[string]int64 // payment ID -> paise refunded so far
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{
+		orders:   make(map[string]GatewayOrder),
+		payments: make(map[string]GatewayPayment),
+		refunded: make(map[string]int64),
+	}
+}
+
+func fakeID(prefix string) string {
+	b := make([]byte, 7)
+	rand.Read(b)
+	return prefix + "_fake" + hex.EncodeToString(b)
+}
+
+func (g *fakeGateway) Name() string  { return "fake" }
+func (g *fakeGateway) KeyID() string { return "rzp_test_fake" }
+
+func (g *fakeGateway) CreateOrder(amount int64, currency, receipt string, notes map[string]string) (GatewayOrder, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	o := GatewayOrder{ID: fakeID("order"), Amount: amount, Currency: currency, Receipt: receipt, Status: "created"}
+	g.orders[o.ID] = o
+	return o, nil
+}
+
+func (g *fakeGateway) VerifySignature(orderID, paymentID, signature string) bool {
+	return validRazorpaySignature([]byte(orderID+"|"+paymentID), signature, fakeGatewaySecret)
+}
+
+func (g *fakeGateway) VerifyWebhookSignature(body []byte, signature string) bool {
+	return validRazorpaySignature(body, signature, fakeGatewaySecret)
+}
+
+func (g *fakeGateway) FetchPayment(paymentID string) (GatewayPayment, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, ok := g.payments[paymentID]
+	if !ok {
+		return p, fmt.Errorf("payment %s not found", paymentID)
+	}
+	return p, nil
+}
+
+func (g *fakeGateway) Refund(paymentID string, amount int64) (GatewayRefund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, ok := g.payments[paymentID]
+	if !ok || (p.Status != "captured" && p.Status != "refunded") {
+		return GatewayRefund{}, fmt.Errorf("payment %s is not captured", paymentID)
+	}
+	remaining := p.Amount - g.refunded[paymentID]
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return GatewayRefund{}, fmt.Errorf("refund amount %d exceeds refundable %d", amount, remaining)
+	}
+	g.refunded[paymentID] += amount
+	if g.refunded[paymentID] == p.Amount {
+		p.Status = "refunded"
+		g.payments[paymentID] = p
+	}
+	return GatewayRefund{ID: fakeID("rfnd"), PaymentID: paymentID, Amount: amount, Status: "processed"}, nil
+}
+
+// Capture simulates a successful checkout of an order, returning the payment and the
+// signature checkout would pass to the browser for /razorpay/verify.
+func (g *fakeGateway) Capture(orderID string) (GatewayPayment, string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	o, ok := g.orders[orderID]
+	if !ok {
+		return GatewayPayment{}, "", fmt.Errorf("order %s not found", orderID)
+	}
+	p := GatewayPayment{ID: fakeID("pay"), OrderID: orderID, Amount: o.Amount, Currency: o.Currency, Status: "captured", CreatedAt: time.Now()}
+	g.payments[p.ID] = p
+	o.Status = "paid"
+	g.orders[orderID] = o
+	return p, razorpaySignature([]byte(orderID+"|"+p.ID), fakeGatewaySecret), nil
+}
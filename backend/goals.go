@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// goalCapacityMonths is how many past months of income and expenses the user's typical
+// monthly savings are averaged over
+const goalCapacityMonths = 3
+
+// SavingsGoal is an amount the user wants to have saved by a deadline. Progress comes from
+// recorded contributions plus, when a category is linked, expenses in that category (such as
+// transfers to a savings account recorded as expenses) since the goal started, and when an
+// account is linked, how much that account's balance has grown since the goal started.
+type SavingsGoal struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	Name         string    `json:"name"`
+	TargetAmount float64   `json:"target_amount"`
+	Deadline     time.Time `json:"deadline"`
+	Category     string    `json:"category"`
+	AccountID    *int      `json:"account_id"`
+	StartDate    time.Time `json:"start_date"`
+}
+
+// MarshalJSON formats the dates as YYYY-MM-DD
+func (g SavingsGoal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID           int     `json:"id"`
+		UserID       int     `json:"user_id"`
+		Name         string  `json:"name"`
+		TargetAmount float64 `json:"target_amount"`
+		Deadline     string  `json:"deadline"`
+		Category     string  `json:"category"`
+		AccountID    *int    `json:"account_id"`
+		StartDate    string  `json:"start_date"`
+	}{g.ID, g.UserID, g.Name, g.TargetAmount, g.Deadline.Format("2006-01-02"), g.Category, g.AccountID, g.StartDate.Format("2006-01-02")})
+}
+
+const goalColumns = "id, user_id, name, target_amount, deadline, category, account_id, start_date"
+
+func scanGoal(row pgx.Row) (SavingsGoal, error) {
+	var g SavingsGoal
+	err := row.Scan(&g.ID, &g.UserID, &g.Name, &g.TargetAmount, &g.Deadline, &g.Category, &g.AccountID, &g.StartDate)
+	return g, err
+}
+
+// lockOwnedGoal loads a goal owned by userID, locking it for the rest of the transaction
+func lockOwnedGoal(ctx context.Context, tx pgx.Tx, id, userID int) (SavingsGoal, error) {
+	g, err := scanGoal(tx.QueryRow(ctx, "SELECT "+goalColumns+" FROM savings_goals WHERE id=$1 AND user_id=$2 FOR UPDATE", id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return g, newAPIError(http.StatusNotFound, "Goal not found")
+	}
+	return g, err
+}
+
+// GoalContribution is money put towards (or, when negative, taken from) a goal
+type GoalContribution struct {
+	ID     int       `json:"id"`
+	GoalID int       `json:"goal_id"`
+	Amount float64   `json:"amount"`
+	Date   time.Time `json:"date"`
+	Note   string    `json:"note"`
+}
+
+// MarshalJSON formats Date as YYYY-MM-DD
+func (gc GoalContribution) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID     int     `json:"id"`
+		GoalID int     `json:"goal_id"`
+		Amount float64 `json:"amount"`
+		Date   string  `json:"date"`
+		Note   string  `json:"note"`
+	}{gc.ID, gc.GoalID, gc.Amount, gc.Date.Format("2006-01-02"), gc.Note})
+}
+
+// goalStatus is a goal's progress measured against its schedule and the user's actual savings
+type goalStatus struct {
+	Goal            SavingsGoal `json:"goal"`
+	Contributed     float64     `json:"contributed"`      // recorded contributions
+	CategorySaved   float64     `json:"category_saved"`   // expenses in the linked category since the start date
+	AccountGrowth   float64     `json:"account_growth"`   // growth of the linked account's balance since the start date
+	Saved           float64     `json:"saved"`            // contributed + category_saved + account_growth
+	Remaining       float64     `json:"remaining"`        // never negative
+	Progress        float64     `json:"progress"`         // percent of the target saved
+	ExpectedSaved   float64     `json:"expected_saved"`   // saved by today if saving evenly from start to deadline
+	MonthsLeft      float64     `json:"months_left"`      // until the deadline
+	RequiredMonthly float64     `json:"required_monthly"` // needed each month from now to reach the target in time
+	MonthlySavings  float64     `json:"monthly_savings"`  // average income less expenses over recent months
+	Affordable      bool        `json:"affordable"`       // monthly_savings covers required_monthly
+	OnTrack         bool        `json:"on_track"`
+	Behind          bool        `json:"behind"`
+	Status          string      `json:"status"` // completed, on_track, behind or overdue
+}
+
+// computeGoalStatus measures a goal on today's date given what has been saved towards it and
+// the user's average monthly savings
+func computeGoalStatus(g SavingsGoal, contributed, categorySaved, accountGrowth, monthlySavings float64, today time.Time) goalStatus {
+	s := goalStatus{
+		Goal: g, Contributed: roundMoney(contributed), CategorySaved: roundMoney(categorySaved),
+		AccountGrowth: roundMoney(accountGrowth), MonthlySavings: roundMoney(monthlySavings),
+	}
+	s.Saved = roundMoney(contributed + categorySaved + accountGrowth)
+	s.Remaining = roundMoney(math.Max(0, g.TargetAmount-s.Saved))
+	s.Progress = math.Round(s.Saved/g.TargetAmount*1000) / 10
+
+	total := g.Deadline.Sub(g.StartDate).Hours() / 24
+	elapsed := today.Sub(g.StartDate).Hours() / 24
+	share := 1.0
+	if total > 0 && elapsed < total {
+		share = math.Max(0, elapsed/total)
+	}
+	s.ExpectedSaved = roundMoney(g.TargetAmount * share)
+
+	daysLeft := g.Deadline.Sub(today).Hours() / 24
+	s.MonthsLeft = math.Max(0, math.Round(daysLeft/30.44*10)/10)
+	// Anything due within a month has to be found this month
+	s.RequiredMonthly = roundMoney(s.Remaining / math.Max(1, daysLeft/30.44))
+	s.Affordable = s.Remaining == 0 || monthlySavings >= s.RequiredMonthly
+
+	switch {
+	case s.Remaining == 0:
+		s.Status, s.OnTrack = "completed", true
+	case daysLeft < 0:
+		s.Status, s.Behind = "overdue", true
+	case s.Saved >= s.ExpectedSaved && s.Affordable:
+		s.Status, s.OnTrack = "on_track", true
+	default:
+		s.Status, s.Behind = "behind", true
+	}
+	return s
+}
+
+// averageMonthlySavings is the user's income less expenses per month over the last few full months
+func averageMonthlySavings(ctx context.Context, userID int, now time.Time) (float64, error) {
+	to := monthStart(now)
+	from := to.AddDate(0, -goalCapacityMonths, 0)
+	income, _, err := sumIncome(ctx, userID, from, to.AddDate(0, 0, -1))
+	if err != nil {
+		return 0, err
+	}
+	expenses, err := sumExpenses(ctx, userID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return (income - expenses) / goalCapacityMonths, nil
+}
+
+// goalStatuses computes the status of each goal for userID
+func goalStatuses(ctx context.Context, userID int, goals []SavingsGoal) ([]goalStatus, float64, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthlySavings, err := averageMonthlySavings(ctx, userID, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	statuses := make([]goalStatus, 0, len(goals))
+	for _, g := range goals {
+		var contributed, categorySaved, accountSaved float64
+		err := db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM goal_contributions WHERE goal_id=$1", g.ID).Scan(&contributed)
+		if err != nil {
+			return nil, 0, err
+		}
+		if g.Category != "" {
+			err := db.QueryRow(ctx,
+				"SELECT COALESCE(SUM(amount), 0) FROM expenses WHERE user_id=$1 AND category=$2 AND date >= $3 AND date <= $4",
+				userID, g.Category, g.StartDate, today).Scan(&categorySaved)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		if g.AccountID != nil {
+			a, err := loadOwnedAccount(ctx, db, *g.AccountID, userID)
+			if err != nil {
+				return nil, 0, err
+			}
+			// Only what arrived after the goal started; money already there was saved for something else
+			entries, err := accountLedger(ctx, userID, a)
+			if err != nil {
+				return nil, 0, err
+			}
+			accountSaved = ledgerGrowthSince(entries, g.StartDate)
+		}
+		statuses = append(statuses, computeGoalStatus(g, contributed, categorySaved, accountSaved, monthlySavings, today))
+	}
+	return statuses, roundMoney(monthlySavings), nil
+}
+
+// goalInput is the request body for creating or updating a goal
+type goalInput struct {
+	Name         string  `json:"name"`
+	TargetAmount float64 `json:"target_amount"`
+	Deadline     string  `json:"deadline"`
+	Category     string  `json:"category"`
+	AccountID    *int    `json:"account_id"`
+	StartDate    string  `json:"start_date"`
+}
+
+// toGoal validates the input; the start date defaults to today
+func (in goalInput) toGoal() (SavingsGoal, error) {
+	g := SavingsGoal{Name: strings.TrimSpace(in.Name), TargetAmount: in.TargetAmount, Category: in.Category, AccountID: in.AccountID}
+	if g.Name == "" {
+		return g, errors.New("name required")
+	}
+	if g.TargetAmount <= 0 {
+		return g, errors.New("target_amount must be positive")
+	}
+	now := time.Now()
+	g.StartDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if in.StartDate != "" {
+		t, err := time.Parse("2006-01-02", in.StartDate)
+		if err != nil {
+			return g, errors.New("Invalid start_date format. Use YYYY-MM-DD.")
+		}
+		g.StartDate = t
+	}
+	t, err := time.Parse("2006-01-02", in.Deadline)
+	if err != nil {
+		return g, errors.New("deadline required in YYYY-MM-DD format")
+	}
+	if !t.After(g.StartDate) {
+		return g, errors.New("deadline must be after start_date")
+	}
+	g.Deadline = t
+	return g, nil
+}
+
+func registerGoalRoutes(auth *gin.RouterGroup) {
+	auth.GET("/goals", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(), "SELECT "+goalColumns+" FROM savings_goals WHERE user_id=$1 ORDER BY deadline, id", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		goals := make([]SavingsGoal, 0)
+		for rows.Next() {
+			if g, err := scanGoal(rows); err == nil {
+				goals = append(goals, g)
+			}
+		}
+		c.JSON(http.StatusOK, goals)
+	})
+
+	// Progress of every goal, with the total monthly contribution they need against the
+	// user's average monthly savings
+	auth.GET("/goals/status", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		rows, err := db.Query(ctx, "SELECT "+goalColumns+" FROM savings_goals WHERE user_id=$1 ORDER BY deadline, id", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		var goals []SavingsGoal
+		for rows.Next() {
+			if g, err := scanGoal(rows); err == nil {
+				goals = append(goals, g)
+			}
+		}
+		rows.Close()
+		statuses, monthlySavings, err := goalStatuses(ctx, userID, goals)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		var required float64
+		for _, s := range statuses {
+			if s.Status == "on_track" || s.Status == "behind" {
+				required += s.RequiredMonthly
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"goals":                  statuses,
+			"monthly_savings":        monthlySavings,
+			"required_monthly_total": roundMoney(required),
+			"affordable":             monthlySavings >= required,
+		})
+	})
+
+	auth.GET("/goals/:id/status", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		g, err := scanGoal(db.QueryRow(ctx, "SELECT "+goalColumns+" FROM savings_goals WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		statuses, _, err := goalStatuses(ctx, userID, []SavingsGoal{g})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, statuses[0])
+	})
+
+	auth.POST("/goals", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input goalInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		g, err := input.toGoal()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			if g.Category, err = resolveCategory(ctx, tx, userID, g.Category); err != nil {
+				return err
+			}
+			if err := checkAccount(ctx, tx, userID, g.AccountID); err != nil {
+				return err
+			}
+			g.UserID = userID
+			return tx.QueryRow(ctx,
+				"INSERT INTO savings_goals (user_id, name, target_amount, deadline, category, account_id, start_date) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+				userID, g.Name, g.TargetAmount, g.Deadline, g.Category, g.AccountID, g.StartDate).Scan(&g.ID)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add goal")
+			return
+		}
+		c.JSON(http.StatusCreated, g)
+	})
+
+	auth.PUT("/goals/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input goalInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		g, err := input.toGoal()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			existing, err := lockOwnedGoal(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			if input.StartDate == "" {
+				g.StartDate = existing.StartDate
+				if !g.Deadline.After(g.StartDate) {
+					return newAPIError(http.StatusBadRequest, "deadline must be after start_date")
+				}
+			}
+			if g.Category, err = resolveCategory(ctx, tx, userID, g.Category); err != nil {
+				return err
+			}
+			if err := checkAccount(ctx, tx, userID, g.AccountID); err != nil {
+				return err
+			}
+			g.ID, g.UserID = existing.ID, userID
+			_, err = tx.Exec(ctx,
+				"UPDATE savings_goals SET name=$1, target_amount=$2, deadline=$3, category=$4, account_id=$5, start_date=$6 WHERE id=$7",
+				g.Name, g.TargetAmount, g.Deadline, g.Category, g.AccountID, g.StartDate, g.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update goal")
+			return
+		}
+		c.JSON(http.StatusOK, g)
+	})
+
+	auth.DELETE("/goals/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "DELETE FROM savings_goals WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Goal deleted"})
+	})
+
+	auth.GET("/goals/:id/contributions", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(),
+			"SELECT gc.id, gc.goal_id, gc.amount, gc.date, gc.note FROM goal_contributions gc JOIN savings_goals g ON g.id=gc.goal_id "+
+				"WHERE gc.goal_id=$1 AND g.user_id=$2 ORDER BY gc.date DESC, gc.id DESC",
+			atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		contributions := make([]GoalContribution, 0)
+		for rows.Next() {
+			var gc GoalContribution
+			if err := rows.Scan(&gc.ID, &gc.GoalID, &gc.Amount, &gc.Date, &gc.Note); err == nil {
+				contributions = append(contributions, gc)
+			}
+		}
+		c.JSON(http.StatusOK, contributions)
+	})
+
+	// Record a contribution; a negative amount is a withdrawal. Reaching the target notifies the user.
+	auth.POST("/goals/:id/contributions", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			Amount float64 `json:"amount"`
+			Date   string  `json:"date"`
+			Note   string  `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Amount == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must not be zero"})
+			return
+		}
+		gc := GoalContribution{Amount: input.Amount, Note: input.Note, Date: time.Now()}
+		if input.Date != "" {
+			t, err := time.Parse("2006-01-02", input.Date)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+				return
+			}
+			gc.Date = t
+		}
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			g, err := lockOwnedGoal(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			var before float64
+			if err := tx.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM goal_contributions WHERE goal_id=$1", g.ID).Scan(&before); err != nil {
+				return err
+			}
+			if before+gc.Amount < 0 {
+				return newAPIError(http.StatusBadRequest, "Withdrawal exceeds the amount contributed")
+			}
+			gc.GoalID = g.ID
+			err = tx.QueryRow(ctx,
+				"INSERT INTO goal_contributions (goal_id, user_id, amount, date, note) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+				g.ID, userID, gc.Amount, gc.Date, gc.Note).Scan(&gc.ID)
+			if err != nil {
+				return err
+			}
+			if before < g.TargetAmount && before+gc.Amount >= g.TargetAmount {
+				return notify(ctx, tx, userID, "goal", fmt.Sprintf("You reached your savings goal %q of ₹%.2f.", g.Name, g.TargetAmount), "goal", g.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add contribution")
+			return
+		}
+		c.JSON(http.StatusCreated, gc)
+	})
+
+	auth.DELETE("/goals/:id/contributions/:contributionId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(),
+			"DELETE FROM goal_contributions WHERE id=$1 AND goal_id=$2 AND user_id=$3",
+			atoi(c.Param("contributionId")), atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete contribution"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Contribution not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Contribution deleted"})
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeGoalStatus(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	// Halfway through the year on 2026-07-02, so 6000 is expected by then
+	g := SavingsGoal{TargetAmount: 12000, StartDate: date("2026-01-01"), Deadline: date("2026-12-31")}
+	tests := []struct {
+		name                                               string
+		contributed, categorySaved, accountGrowth, monthly float64
+		today                                              string
+		wantSaved, wantRemaining, wantExpected             float64
+		wantProgress                                       float64
+		wantStatus                                         string
+	}{
+		{"on track", 4000, 1000, 1500, 2000, "2026-07-02", 6500, 5500, 6000, 54.2, "on_track"},
+		{"behind schedule", 3000, 0, 0, 5000, "2026-07-02", 3000, 9000, 6000, 25, "behind"},
+		{"ahead but unaffordable", 6500, 0, 0, 500, "2026-07-02", 6500, 5500, 6000, 54.2, "behind"},
+		{"account shrank", 6500, 0, -1000, 2000, "2026-07-02", 5500, 6500, 6000, 45.8, "behind"},
+		{"completed", 10000, 2500, 0, 0, "2026-07-02", 12500, 0, 6000, 104.2, "completed"},
+		{"overdue", 6000, 0, 0, 100000, "2027-01-10", 6000, 6000, 12000, 50, "overdue"},
+		{"not started", 0, 0, 0, 2000, "2025-12-01", 0, 12000, 0, 0, "on_track"},
+	}
+	for _, tt := range tests {
+		s := computeGoalStatus(g, tt.contributed, tt.categorySaved, tt.accountGrowth, tt.monthly, date(tt.today))
+		if s.Saved != tt.wantSaved || s.Remaining != tt.wantRemaining || s.ExpectedSaved != tt.wantExpected ||
+			s.Progress != tt.wantProgress || s.Status != tt.wantStatus {
+			t.Errorf("%s: saved %v, remaining %v, expected %v, progress %v, status %q", tt.name, s.Saved, s.Remaining, s.ExpectedSaved, s.Progress, s.Status)
+		}
+		if s.OnTrack == s.Behind {
+			t.Errorf("%s: on_track and behind both %v", tt.name, s.OnTrack)
+		}
+	}
+}
+
+func TestComputeGoalStatusRequiredMonthly(t *testing.T) {
+	g := SavingsGoal{TargetAmount: 3000, StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Deadline: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	// Ten days before the deadline the whole remainder is needed this month
+	s := computeGoalStatus(g, 1000, 0, 0, 1500, time.Date(2026, 2, 19, 0, 0, 0, 0, time.UTC))
+	if s.RequiredMonthly != 2000 || s.MonthsLeft != 0.3 || s.Affordable {
+		t.Errorf("required %v, months left %v, affordable %v", s.RequiredMonthly, s.MonthsLeft, s.Affordable)
+	}
+}
+
+func TestLedgerGrowthSince(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	entries := []ledgerEntry{
+		{Type: "opening", Amount: 50000, date: day(20)},
+		{Type: "income", Amount: 30000, date: day(1)},
+		{Type: "expense", Amount: -2000, date: day(9)},
+		{Type: "income", Amount: 30000, date: day(10)},
+		{Type: "transfer_out", Amount: -5000, date: day(15)},
+	}
+	tests := []struct {
+		start int
+		want  float64
+	}{
+		{1, 53000},
+		{10, 25000},
+		{16, 0},
+	}
+	for _, tt := range tests {
+		if got := ledgerGrowthSince(entries, day(tt.start)); got != tt.want {
+			t.Errorf("ledgerGrowthSince(day %d) = %v, want %v", tt.start, got, tt.want)
+		}
+	}
+}
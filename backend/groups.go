@@ -0,0 +1,682 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Group member roles. The owner manages the group; any member can invite and add expenses.
+// The owner and managers approve and reimburse members' expense reports.
+const (
+	roleOwner   = "owner"
+	roleManager = "manager"
+	roleMember  = "member"
+)
+
+// Invitation statuses
+const (
+	invitationPending  = "pending"
+	invitationAccepted = "accepted"
+	invitationDeclined = "declined"
+	invitationRevoked  = "revoked"
+)
+
+// Group is a household, trip or team whose members share expenses
+type Group struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	CreatedBy int           `json:"created_by"`
+	CreatedAt time.Time     `json:"created_at"`
+	Members   []GroupMember `json:"members"`
+}
+
+// GroupMember is a user belonging to a group
+type GroupMember struct {
+	UserID   int       `json:"user_id"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// GroupInvitation invites whoever registers with Email to join a group
+type GroupInvitation struct {
+	ID        int       `json:"id"`
+	GroupID   int       `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	Email     string    `json:"email"`
+	InvitedBy int       `json:"invited_by"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const invitationColumns = "i.id, i.group_id, g.name, i.email, i.invited_by, i.status, i.created_at"
+
+func scanInvitation(row pgx.Row) (GroupInvitation, error) {
+	var inv GroupInvitation
+	err := row.Scan(&inv.ID, &inv.GroupID, &inv.GroupName, &inv.Email, &inv.InvitedBy, &inv.Status, &inv.CreatedAt)
+	return inv, err
+}
+
+// groupRole returns userID's role in a group. Groups the user doesn't belong to are a 404.
+func groupRole(ctx context.Context, q querier, groupID, userID int) (string, error) {
+	var role string
+	err := q.QueryRow(ctx, "SELECT role FROM group_members WHERE group_id=$1 AND user_id=$2", groupID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", newAPIError(http.StatusNotFound, "Group not found")
+	}
+	return role, err
+}
+
+// loadGroup loads a group with its members
+func loadGroup(ctx context.Context, q querier, groupID int) (Group, error) {
+	var g Group
+	err := q.QueryRow(ctx, "SELECT id, name, created_by, created_at FROM groups WHERE id=$1", groupID).Scan(&g.ID, &g.Name, &g.CreatedBy, &g.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return g, newAPIError(http.StatusNotFound, "Group not found")
+	}
+	if err != nil {
+		return g, err
+	}
+	rows, err := q.Query(ctx,
+		"SELECT m.user_id, u.name, m.role, m.joined_at FROM group_members m JOIN users u ON u.id=m.user_id WHERE m.group_id=$1 ORDER BY m.joined_at, u.name", groupID)
+	if err != nil {
+		return g, err
+	}
+	defer rows.Close()
+	g.Members = make([]GroupMember, 0)
+	for rows.Next() {
+		var m GroupMember
+		if err := rows.Scan(&m.UserID, &m.Name, &m.Role, &m.JoinedAt); err != nil {
+			return g, err
+		}
+		g.Members = append(g.Members, m)
+	}
+	return g, rows.Err()
+}
+
+// checkGroupParticipants verifies that everyone in a split belongs to the group
+func checkGroupParticipants(ctx context.Context, q querier, groupID int, parts []splitInput) error {
+	for _, p := range parts {
+		if _, err := groupRole(ctx, q, groupID, p.UserID); err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("User %d is not a member of the group", p.UserID))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// groupExpenseInput is the request body for adding a group expense. Without splits the expense
+// is shared equally by all members.
+type groupExpenseInput struct {
+	expenseInput
+	SplitMethod string       `json:"split_method"`
+	Splits      []splitInput `json:"splits"`
+}
+
+// defaultSplit makes a split without a method equal, and an equal split without participants
+// cover all of the group's members
+func defaultSplit(g Group, method string, parts []splitInput) (string, []splitInput) {
+	if method == "" {
+		method = "equal"
+	}
+	if len(parts) == 0 && method == "equal" {
+		for _, m := range g.Members {
+			parts = append(parts, splitInput{UserID: m.UserID})
+		}
+	}
+	return method, parts
+}
+
+func registerGroupRoutes(auth *gin.RouterGroup) {
+	// Groups the user belongs to
+	auth.GET("/groups", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		rows, err := db.Query(ctx,
+			"SELECT g.id FROM groups g JOIN group_members m ON m.group_id=g.id WHERE m.user_id=$1 ORDER BY g.name", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+		groups := make([]Group, 0, len(ids))
+		for _, id := range ids {
+			g, err := loadGroup(ctx, db, id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+				return
+			}
+			groups = append(groups, g)
+		}
+		c.JSON(http.StatusOK, groups)
+	})
+
+	auth.POST("/groups", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+			return
+		}
+		ctx := context.Background()
+		var g Group
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var id int
+			if err := tx.QueryRow(ctx, "INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id", name, userID).Scan(&id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, $3)", id, userID, roleOwner); err != nil {
+				return err
+			}
+			var err error
+			g, err = loadGroup(ctx, tx, id)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to create group")
+			return
+		}
+		c.JSON(http.StatusCreated, g)
+	})
+
+	// A group with its members and, for members, its pending invitations
+	auth.GET("/groups/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		if _, err := groupRole(ctx, db, groupID, userID); err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		g, err := loadGroup(ctx, db, groupID)
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		rows, err := db.Query(ctx,
+			"SELECT "+invitationColumns+" FROM group_invitations i JOIN groups g ON g.id=i.group_id WHERE i.group_id=$1 AND i.status=$2 ORDER BY i.created_at",
+			groupID, invitationPending)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		invitations := make([]GroupInvitation, 0)
+		for rows.Next() {
+			if inv, err := scanInvitation(rows); err == nil {
+				invitations = append(invitations, inv)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"group": g, "invitations": invitations})
+	})
+
+	// Rename a group; owner only
+	auth.PUT("/groups/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+			return
+		}
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		role, err := groupRole(ctx, db, groupID, userID)
+		if err != nil {
+			respondError(c, err, "Failed to update group")
+			return
+		}
+		if role != roleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the group owner can rename it"})
+			return
+		}
+		if _, err := db.Exec(ctx, "UPDATE groups SET name=$1 WHERE id=$2", name, groupID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+			return
+		}
+		g, err := loadGroup(ctx, db, groupID)
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		c.JSON(http.StatusOK, g)
+	})
+
+	// Delete a group without expenses; owner only
+	auth.DELETE("/groups/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var role string
+			err := tx.QueryRow(ctx, "SELECT role FROM group_members WHERE group_id=$1 AND user_id=$2 FOR UPDATE", groupID, userID).Scan(&role)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Group not found")
+			}
+			if err != nil {
+				return err
+			}
+			if role != roleOwner {
+				return newAPIError(http.StatusForbidden, "Only the group owner can delete it")
+			}
+			var hasExpenses bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM expenses WHERE group_id=$1)", groupID).Scan(&hasExpenses); err != nil {
+				return err
+			}
+			if hasExpenses {
+				return newAPIError(http.StatusConflict, "Group has expenses")
+			}
+			var hasReports bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM expense_reports WHERE group_id=$1)", groupID).Scan(&hasReports); err != nil {
+				return err
+			}
+			if hasReports {
+				return newAPIError(http.StatusConflict, "Group has expense reports")
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM groups WHERE id=$1", groupID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to delete group")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Group deleted"})
+	})
+
+	// Invite someone by email. Registered users are notified; anyone else sees the invitation
+	// once they sign up with that email.
+	auth.POST("/groups/:id/invitations", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			Email string `json:"email"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		email := strings.TrimSpace(input.Email)
+		if !strings.Contains(email, "@") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required"})
+			return
+		}
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		var inv GroupInvitation
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			if _, err := groupRole(ctx, tx, groupID, userID); err != nil {
+				return err
+			}
+			var invitee *int
+			err := tx.QueryRow(ctx, "SELECT id FROM users WHERE lower(email)=lower($1)", email).Scan(&invitee)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+			if invitee != nil {
+				if _, err := groupRole(ctx, tx, groupID, *invitee); err == nil {
+					return newAPIError(http.StatusConflict, "User is already a member")
+				}
+			}
+			var id int
+			err = tx.QueryRow(ctx, "INSERT INTO group_invitations (group_id, email, invited_by) VALUES ($1, $2, $3) RETURNING id", groupID, email, userID).Scan(&id)
+			if isUniqueViolation(err) {
+				return newAPIError(http.StatusConflict, "An invitation is already pending for this email")
+			}
+			if err != nil {
+				return err
+			}
+			inv, err = scanInvitation(tx.QueryRow(ctx, "SELECT "+invitationColumns+" FROM group_invitations i JOIN groups g ON g.id=i.group_id WHERE i.id=$1", id))
+			if err != nil || invitee == nil {
+				return err
+			}
+			return notify(ctx, tx, *invitee, "group_invitation", fmt.Sprintf("You have been invited to join %s.", inv.GroupName), "group_invitation", inv.ID)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to invite")
+			return
+		}
+		c.JSON(http.StatusCreated, inv)
+	})
+
+	// Revoke a pending invitation; the owner or whoever sent it
+	auth.DELETE("/groups/:id/invitations/:invitationId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		role, err := groupRole(ctx, db, groupID, userID)
+		if err != nil {
+			respondError(c, err, "Failed to revoke invitation")
+			return
+		}
+		res, err := db.Exec(ctx,
+			"UPDATE group_invitations SET status=$1, responded_at=now() WHERE id=$2 AND group_id=$3 AND status=$4 AND (invited_by=$5 OR $6)",
+			invitationRevoked, atoi(c.Param("invitationId")), groupID, invitationPending, userID, role == roleOwner)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+	})
+
+	// Pending invitations to the user's email
+	auth.GET("/invitations", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(),
+			"SELECT "+invitationColumns+" FROM group_invitations i JOIN groups g ON g.id=i.group_id JOIN users u ON lower(u.email)=lower(i.email) "+
+				"WHERE u.id=$1 AND i.status=$2 ORDER BY i.created_at DESC", userID, invitationPending)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		invitations := make([]GroupInvitation, 0)
+		for rows.Next() {
+			if inv, err := scanInvitation(rows); err == nil {
+				invitations = append(invitations, inv)
+			}
+		}
+		c.JSON(http.StatusOK, invitations)
+	})
+
+	// respondInvitation accepts or declines an invitation to the user's email
+	respondInvitation := func(c *gin.Context, accept bool) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		var inv GroupInvitation
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			inv, err = scanInvitation(tx.QueryRow(ctx,
+				"SELECT "+invitationColumns+" FROM group_invitations i JOIN groups g ON g.id=i.group_id JOIN users u ON lower(u.email)=lower(i.email) "+
+					"WHERE i.id=$1 AND u.id=$2 FOR UPDATE OF i", atoi(c.Param("id")), userID))
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Invitation not found")
+			}
+			if err != nil {
+				return err
+			}
+			if inv.Status != invitationPending {
+				return newAPIError(http.StatusConflict, "Invitation is already "+inv.Status)
+			}
+			inv.Status = invitationDeclined
+			if accept {
+				inv.Status = invitationAccepted
+				if _, err := tx.Exec(ctx,
+					"INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", inv.GroupID, userID, roleMember); err != nil {
+					return err
+				}
+			}
+			_, err = tx.Exec(ctx, "UPDATE group_invitations SET status=$1, responded_at=now() WHERE id=$2", inv.Status, inv.ID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to respond to invitation")
+			return
+		}
+		c.JSON(http.StatusOK, inv)
+	}
+	auth.POST("/invitations/:id/accept", func(c *gin.Context) { respondInvitation(c, true) })
+	auth.POST("/invitations/:id/decline", func(c *gin.Context) { respondInvitation(c, false) })
+
+	// Remove a member, or leave the group when removing yourself. Only the owner can remove
+	// others, and members who are part of the group's expenses stay until those are cleared.
+	auth.DELETE("/groups/:id/members/:userId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		memberID := atoi(c.Param("userId"))
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			role, err := groupRole(ctx, tx, groupID, userID)
+			if err != nil {
+				return err
+			}
+			if memberID != userID && role != roleOwner {
+				return newAPIError(http.StatusForbidden, "Only the group owner can remove members")
+			}
+			memberRole, err := groupRole(ctx, tx, groupID, memberID)
+			if err != nil {
+				return newAPIError(http.StatusNotFound, "Member not found")
+			}
+			if memberRole == roleOwner {
+				return newAPIError(http.StatusConflict, "The owner can't leave the group")
+			}
+			var involved bool
+			err = tx.QueryRow(ctx,
+				"SELECT EXISTS (SELECT 1 FROM expenses e LEFT JOIN expense_splits s ON s.expense_id=e.id WHERE e.group_id=$1 AND (e.user_id=$2 OR s.user_id=$2))",
+				groupID, memberID).Scan(&involved)
+			if err != nil {
+				return err
+			}
+			if involved {
+				return newAPIError(http.StatusConflict, "Member is part of the group's expenses")
+			}
+			var claiming bool
+			err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM expense_reports WHERE group_id=$1 AND user_id=$2 AND status IN ($3, $4))",
+				groupID, memberID, reportSubmitted, reportApproved).Scan(&claiming)
+			if err != nil {
+				return err
+			}
+			if claiming {
+				return newAPIError(http.StatusConflict, "Member has expense reports awaiting approval or reimbursement")
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM group_members WHERE group_id=$1 AND user_id=$2", groupID, memberID)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to remove member")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+	})
+
+	// Make a member a manager or back to a plain member; owner only
+	auth.PUT("/groups/:id/members/:userId", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Role != roleManager && input.Role != roleMember {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be manager or member"})
+			return
+		}
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		memberID := atoi(c.Param("userId"))
+		var member GroupMember
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			role, err := groupRole(ctx, tx, groupID, userID)
+			if err != nil {
+				return err
+			}
+			if role != roleOwner {
+				return newAPIError(http.StatusForbidden, "Only the group owner can change roles")
+			}
+			err = tx.QueryRow(ctx,
+				"UPDATE group_members m SET role=$1 FROM users u WHERE u.id=m.user_id AND m.group_id=$2 AND m.user_id=$3 AND m.role<>$4 "+
+					"RETURNING m.user_id, u.name, m.role, m.joined_at",
+				input.Role, groupID, memberID, roleOwner).Scan(&member.UserID, &member.Name, &member.Role, &member.JoinedAt)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Member not found")
+			}
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to change role")
+			return
+		}
+		c.JSON(http.StatusOK, member)
+	})
+
+	// The group's expenses with how each is split, newest first
+	auth.GET("/groups/:id/expenses", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		if _, err := groupRole(ctx, db, groupID, userID); err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		expenses, err := sharedExpenses(ctx, "group_id=$1", groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, expenses)
+	})
+
+	// Add an expense paid by the user and shared with the group
+	auth.POST("/groups/:id/expenses", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input groupExpenseInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		exp, err := input.toExpense()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		if _, err := groupRole(ctx, db, groupID, userID); err != nil {
+			respondError(c, err, "Failed to add expense")
+			return
+		}
+		exp.GroupID = &groupID
+		if err := categorizeExpense(c.Request.Context(), userID, &exp); err != nil {
+			respondError(c, err, "Failed to categorize expense")
+			return
+		}
+		var g Group
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			if g, err = loadGroup(ctx, tx, groupID); err != nil {
+				return err
+			}
+			input.SplitMethod, input.Splits = defaultSplit(g, input.SplitMethod, input.Splits)
+			if err := checkGroupParticipants(ctx, tx, groupID, input.Splits); err != nil {
+				return err
+			}
+			if err := insertExpense(ctx, tx, userID, &exp); err != nil {
+				return err
+			}
+			if err := saveSplits(ctx, tx, exp, input.SplitMethod, input.Splits); err != nil {
+				return err
+			}
+			return notifySplit(ctx, tx, exp, g.Name)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add expense")
+			return
+		}
+		checkAnomalies(ctx, userID, expenseSubject(exp))
+		splits, err := loadSplits(ctx, db, []int{exp.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"expense": exp, "split_method": input.SplitMethod, "splits": splits[exp.ID]})
+	})
+
+	// Change how a group expense is split; only the member who paid can
+	auth.PUT("/groups/:id/expenses/:expenseId/splits", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			SplitMethod string       `json:"split_method"`
+			Splits      []splitInput `json:"splits"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		groupID, expenseID := atoi(c.Param("id")), atoi(c.Param("expenseId"))
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			exp, err := lockOwnedExpense(ctx, tx, expenseID, userID)
+			if err != nil {
+				return err
+			}
+			if exp.GroupID == nil || *exp.GroupID != groupID {
+				return newAPIError(http.StatusNotFound, "Expense not found")
+			}
+			g, err := loadGroup(ctx, tx, groupID)
+			if err != nil {
+				return err
+			}
+			input.SplitMethod, input.Splits = defaultSplit(g, input.SplitMethod, input.Splits)
+			if err := checkGroupParticipants(ctx, tx, groupID, input.Splits); err != nil {
+				return err
+			}
+			return saveSplits(ctx, tx, exp, input.SplitMethod, input.Splits)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update split")
+			return
+		}
+		splits, err := loadSplits(ctx, db, []int{expenseID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"split_method": input.SplitMethod, "splits": splits[expenseID]})
+	})
+
+	// Each member's balance in the group, who owes whom, and the fewest transfers that settle up
+	auth.GET("/groups/:id/balances", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		groupID := atoi(c.Param("id"))
+		if _, err := groupRole(ctx, db, groupID, userID); err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		g, err := loadGroup(ctx, db, groupID)
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		members, debts, err := shareBalances(ctx, db, balanceScope{GroupID: &groupID}, g.Members)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"members": members, "debts": debts, "settle_up": simplifyDebts(members)})
+	})
+}
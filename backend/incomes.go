@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// incomeSources are the kinds of income a user can record
+var incomeSources = map[string]bool{
+	"salary": true, "freelance": true, "refund": true, "interest": true, "investment": true, "gift": true, "other": true,
+}
+
+// incomeRecurrences are the supported repeat intervals; "" is a one-off income
+var incomeRecurrences = map[string]bool{"": true, "weekly": true, "monthly": true, "yearly": true}
+
+// Income is money received. A recurring income repeats from Date until EndDate, or indefinitely.
+type Income struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Date        time.Time  `json:"date"`
+	Amount      float64    `json:"amount"`
+	Source      string     `json:"source"`
+	Description string     `json:"description"`
+	Recurrence  string     `json:"recurrence"`
+	EndDate     *time.Time `json:"end_date"`
+	AccountID   *int       `json:"account_id"` // account it is paid into, if known
+}
+
+// MarshalJSON formats the dates as YYYY-MM-DD
+func (in Income) MarshalJSON() ([]byte, error) {
+	var end *string
+	if in.EndDate != nil {
+		s := in.EndDate.Format("2006-01-02")
+		end = &s
+	}
+	return json.Marshal(struct {
+		ID          int     `json:"id"`
+		UserID      int     `json:"user_id"`
+		Date        string  `json:"date"`
+		Amount      float64 `json:"amount"`
+		Source      string  `json:"source"`
+		Description string  `json:"description"`
+		Recurrence  string  `json:"recurrence"`
+		EndDate     *string `json:"end_date"`
+		AccountID   *int    `json:"account_id"`
+	}{in.ID, in.UserID, in.Date.Format("2006-01-02"), in.Amount, in.Source, in.Description, in.Recurrence, end, in.AccountID})
+}
+
+const incomeColumns = "id, user_id, date, amount, source, description, recurrence, end_date, account_id"
+
+func scanIncome(row pgx.Row) (Income, error) {
+	var in Income
+	err := row.Scan(&in.ID, &in.UserID, &in.Date, &in.Amount, &in.Source, &in.Description, &in.Recurrence, &in.EndDate, &in.AccountID)
+	return in, err
+}
+
+// addMonths moves t forward n months, keeping the day of month but clamping it to the month's
+// last day, so an income on the 31st falls on the 30th or the 28th in shorter months
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// occurrences returns the dates in [from, to] on which the income is received
+func (in Income) occurrences(from, to time.Time) []time.Time {
+	var dates []time.Time
+	for n := 0; ; n++ {
+		var d time.Time
+		switch in.Recurrence {
+		case "weekly":
+			d = in.Date.AddDate(0, 0, 7*n)
+		case "monthly":
+			d = addMonths(in.Date, n)
+		case "yearly":
+			d = addMonths(in.Date, 12*n)
+		default:
+			d = in.Date
+		}
+		if d.After(to) || (in.EndDate != nil && d.After(*in.EndDate)) {
+			break
+		}
+		if !d.Before(from) {
+			dates = append(dates, d)
+		}
+		if in.Recurrence == "" {
+			break
+		}
+	}
+	return dates
+}
+
+// incomeOccurrence is one receipt of an income within a period
+type incomeOccurrence struct {
+	IncomeID    int     `json:"income_id"`
+	Date        string  `json:"date"`
+	Amount      float64 `json:"amount"`
+	Source      string  `json:"source"`
+	Description string  `json:"description"`
+	Recurring   bool    `json:"recurring"`
+}
+
+// incomeOccurrences expands a user's incomes into the receipts falling in [from, to]
+func incomeOccurrences(ctx context.Context, userID int, from, to time.Time) ([]incomeOccurrence, error) {
+	rows, err := db.Query(ctx,
+		"SELECT "+incomeColumns+" FROM incomes WHERE user_id=$1 AND date <= $3 AND (end_date IS NULL OR end_date >= $2) ORDER BY date, id",
+		userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	occurrences := make([]incomeOccurrence, 0)
+	for rows.Next() {
+		in, err := scanIncome(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range in.occurrences(from, to) {
+			occurrences = append(occurrences, incomeOccurrence{
+				IncomeID: in.ID, Date: d.Format("2006-01-02"), Amount: in.Amount, Source: in.Source,
+				Description: in.Description, Recurring: in.Recurrence != "",
+			})
+		}
+	}
+	return occurrences, rows.Err()
+}
+
+// sumIncome totals a user's income received in [from, to]
+func sumIncome(ctx context.Context, userID int, from, to time.Time) (float64, int, error) {
+	occurrences, err := incomeOccurrences(ctx, userID, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	var total float64
+	for _, o := range occurrences {
+		total += o.Amount
+	}
+	return roundMoney(total), len(occurrences), nil
+}
+
+// cashFlow is income less expenses, and the share of income left over. The rate is nil
+// without income, where it would be meaningless.
+func cashFlow(income, expenses float64) (net float64, savingsRate *float64) {
+	net = roundMoney(income - expenses)
+	if income > 0 {
+		rate := math.Round(net/income*10000) / 10000
+		savingsRate = &rate
+	}
+	return net, savingsRate
+}
+
+// incomeInput is the request body for creating or updating an income
+type incomeInput struct {
+	Date        string  `json:"date"`
+	Amount      float64 `json:"amount"`
+	Source      string  `json:"source"`
+	Description string  `json:"description"`
+	Recurrence  string  `json:"recurrence"`
+	EndDate     string  `json:"end_date"`
+	AccountID   *int    `json:"account_id"`
+}
+
+// toIncome validates the input, defaulting the date to today and the source to other
+func (in incomeInput) toIncome() (Income, error) {
+	inc := Income{Amount: in.Amount, Source: in.Source, Description: in.Description, Recurrence: in.Recurrence, AccountID: in.AccountID}
+	if inc.Amount <= 0 {
+		return inc, errors.New("Amount must be positive")
+	}
+	if inc.Source == "" {
+		inc.Source = "other"
+	}
+	if !incomeSources[inc.Source] {
+		return inc, errors.New("source must be one of salary, freelance, refund, interest, investment, gift or other")
+	}
+	if !incomeRecurrences[inc.Recurrence] {
+		return inc, errors.New("recurrence must be empty, weekly, monthly or yearly")
+	}
+	now := time.Now()
+	inc.Date = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if in.Date != "" {
+		t, err := time.Parse("2006-01-02", in.Date)
+		if err != nil {
+			return inc, errors.New("Invalid date format. Use YYYY-MM-DD.")
+		}
+		inc.Date = t
+	}
+	if in.EndDate != "" {
+		if inc.Recurrence == "" {
+			return inc, errors.New("end_date only applies to recurring income")
+		}
+		t, err := time.Parse("2006-01-02", in.EndDate)
+		if err != nil {
+			return inc, errors.New("Invalid end_date format. Use YYYY-MM-DD.")
+		}
+		if t.Before(inc.Date) {
+			return inc, errors.New("end_date must not be before date")
+		}
+		inc.EndDate = &t
+	}
+	return inc, nil
+}
+
+func registerIncomeRoutes(auth *gin.RouterGroup) {
+	auth.GET("/incomes", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(), "SELECT "+incomeColumns+" FROM incomes WHERE user_id=$1 ORDER BY date DESC, id DESC", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		incomes := make([]Income, 0)
+		for rows.Next() {
+			if in, err := scanIncome(rows); err == nil {
+				incomes = append(incomes, in)
+			}
+		}
+		c.JSON(http.StatusOK, incomes)
+	})
+
+	// Every receipt in ?from= to ?to= (default this month), with recurring incomes expanded
+	auth.GET("/incomes/occurrences", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		from, to, err := summaryPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+			return
+		}
+		occurrences, err := incomeOccurrences(context.Background(), userID, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, occurrences)
+	})
+
+	auth.POST("/incomes", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input incomeInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		inc, err := input.toIncome()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		inc.UserID = userID
+		ctx := context.Background()
+		if err := checkAccount(ctx, db, userID, inc.AccountID); err != nil {
+			respondError(c, err, "Failed to add income")
+			return
+		}
+		err = db.QueryRow(ctx,
+			"INSERT INTO incomes (user_id, date, amount, source, description, recurrence, end_date, account_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+			userID, inc.Date, inc.Amount, inc.Source, inc.Description, inc.Recurrence, inc.EndDate, inc.AccountID).Scan(&inc.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add income"})
+			return
+		}
+		c.JSON(http.StatusCreated, inc)
+	})
+
+	auth.PUT("/incomes/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input incomeInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		inc, err := input.toIncome()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		if err := checkAccount(ctx, db, userID, inc.AccountID); err != nil {
+			respondError(c, err, "Failed to update income")
+			return
+		}
+		inc, err = scanIncome(db.QueryRow(ctx,
+			"UPDATE incomes SET date=$1, amount=$2, source=$3, description=$4, recurrence=$5, end_date=$6, account_id=$7 WHERE id=$8 AND user_id=$9 RETURNING "+incomeColumns,
+			inc.Date, inc.Amount, inc.Source, inc.Description, inc.Recurrence, inc.EndDate, inc.AccountID, atoi(c.Param("id")), userID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Income not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update income"})
+			return
+		}
+		c.JSON(http.StatusOK, inc)
+	})
+
+	auth.DELETE("/incomes/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "DELETE FROM incomes WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete income"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Income not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Income deleted"})
+	})
+}
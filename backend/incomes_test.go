@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		t    string
+		n    int
+		want string
+	}{
+		{"2026-01-15", 1, "2026-02-15"},
+		{"2026-01-31", 1, "2026-02-28"},
+		{"2024-01-31", 1, "2024-02-29"},
+		{"2026-03-31", 1, "2026-04-30"},
+		{"2026-11-30", 3, "2027-02-28"},
+		{"2026-12-31", 2, "2027-02-28"},
+		{"2024-02-29", 12, "2025-02-28"},
+		{"2026-05-31", -1, "2026-04-30"},
+	}
+	for _, tt := range tests {
+		start, _ := time.Parse("2006-01-02", tt.t)
+		if got := addMonths(start, tt.n).Format("2006-01-02"); got != tt.want {
+			t.Errorf("addMonths(%s, %d) = %s, want %s", tt.t, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestIncomeOccurrences(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	end := date("2026-03-31")
+	tests := []struct {
+		name     string
+		income   Income
+		from, to string
+		want     string
+	}{
+		{"one-off inside", Income{Date: date("2026-02-10")}, "2026-02-01", "2026-02-28", "[2026-02-10]"},
+		{"one-off outside", Income{Date: date("2026-01-10")}, "2026-02-01", "2026-02-28", "[]"},
+		{"monthly on the 31st", Income{Date: date("2026-01-31"), Recurrence: "monthly"}, "2026-01-01", "2026-04-30", "[2026-01-31 2026-02-28 2026-03-31 2026-04-30]"},
+		{"monthly, started earlier", Income{Date: date("2025-06-05"), Recurrence: "monthly"}, "2026-02-01", "2026-03-31", "[2026-02-05 2026-03-05]"},
+		{"monthly with end date", Income{Date: date("2026-01-05"), Recurrence: "monthly", EndDate: &end}, "2026-01-01", "2026-06-30", "[2026-01-05 2026-02-05 2026-03-05]"},
+		{"weekly", Income{Date: date("2026-02-02"), Recurrence: "weekly"}, "2026-02-10", "2026-02-28", "[2026-02-16 2026-02-23]"},
+		{"yearly from a leap day", Income{Date: date("2024-02-29"), Recurrence: "yearly"}, "2025-01-01", "2028-12-31", "[2025-02-28 2026-02-28 2027-02-28 2028-02-29]"},
+		{"starts after the period", Income{Date: date("2026-05-01"), Recurrence: "monthly"}, "2026-01-01", "2026-04-30", "[]"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, d := range tt.income.occurrences(date(tt.from), date(tt.to)) {
+			got = append(got, d.Format("2006-01-02"))
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%s: occurrences = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
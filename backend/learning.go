@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// merchantKeyWords is how many leading words of a description identify the merchant
+const merchantKeyWords = 3
+
+// CategoryCorrection records a user changing an expense's category
+type CategoryCorrection struct {
+	ID          int       `json:"id"`
+	ExpenseID   *int      `json:"expense_id"`
+	Description string    `json:"description"`
+	OldCategory string    `json:"old_category"`
+	OldSource   string    `json:"old_source"`
+	NewCategory string    `json:"new_category"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MerchantMapping is a learned merchant -> category mapping for one user.
+// Hits counts the corrections in a row that agreed with the current category.
+type MerchantMapping struct {
+	ID        int       `json:"id"`
+	Merchant  string    `json:"merchant"`
+	Category  string    `json:"category"`
+	Hits      int       `json:"hits"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// merchantKey reduces a description to the words that identify the merchant: the first
+// few normalized words, ignoring tokens with digits (order numbers, dates, amounts).
+// "Swiggy order #4411 - 12/03" and "swiggy order 5120" share the key "swiggy order".
+func merchantKey(description string) string {
+	var words []string
+	for _, w := range strings.Fields(normalizeDescription(description)) {
+		if strings.ContainsAny(w, "0123456789") {
+			continue
+		}
+		words = append(words, w)
+		if len(words) == merchantKeyWords {
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// recordCategoryCorrection stores a correction of exp's category to newCategory and updates
+// the user's merchant mapping for the expense description
+func recordCategoryCorrection(ctx context.Context, tx pgx.Tx, userID int, exp Expense, newCategory string) error {
+	_, err := tx.Exec(ctx,
+		"INSERT INTO category_corrections (user_id, expense_id, description, old_category, old_source, new_category) VALUES ($1, $2, $3, $4, $5, $6)",
+		userID, exp.ID, exp.Description, exp.Category, exp.CategorySource, newCategory)
+	if err != nil {
+		return err
+	}
+	key := merchantKey(exp.Description)
+	if key == "" {
+		return nil
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO merchant_mappings (user_id, merchant, category) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (user_id, merchant) DO UPDATE SET category=EXCLUDED.category, "+
+			"hits=CASE WHEN merchant_mappings.category=EXCLUDED.category THEN merchant_mappings.hits+1 ELSE 1 END, updated_at=now()",
+		userID, key, newCategory)
+	return err
+}
+
+// learnedCategorize looks up the user's merchant mapping for a description
+func learnedCategorize(ctx context.Context, userID int, description string) (categoryPrediction, bool, error) {
+	key := merchantKey(description)
+	if key == "" {
+		return categoryPrediction{}, false, nil
+	}
+	var category string
+	err := db.QueryRow(ctx, "SELECT category FROM merchant_mappings WHERE user_id=$1 AND merchant=$2", userID, key).Scan(&category)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return categoryPrediction{}, false, nil
+	}
+	if err != nil {
+		return categoryPrediction{}, false, err
+	}
+	return categoryPrediction{Category: category, Confidence: 0.95, Source: sourceLearned}, true, nil
+}
+
+func registerLearningRoutes(auth *gin.RouterGroup) {
+	// Merchant mappings learned from the user's category corrections
+	auth.GET("/categories/mappings", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(),
+			"SELECT id, merchant, category, hits, updated_at FROM merchant_mappings WHERE user_id=$1 ORDER BY merchant", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		mappings := make([]MerchantMapping, 0)
+		for rows.Next() {
+			var m MerchantMapping
+			if err := rows.Scan(&m.ID, &m.Merchant, &m.Category, &m.Hits, &m.UpdatedAt); err == nil {
+				mappings = append(mappings, m)
+			}
+		}
+		c.JSON(http.StatusOK, mappings)
+	})
+
+	// Forget a learned mapping; the corrections it was learned from are kept
+	auth.DELETE("/categories/mappings/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "DELETE FROM merchant_mappings WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete mapping"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mapping not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	})
+
+	// Recent category corrections, newest first
+	auth.GET("/categories/corrections", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(),
+			"SELECT id, expense_id, description, old_category, old_source, new_category, created_at FROM category_corrections "+
+				"WHERE user_id=$1 ORDER BY created_at DESC LIMIT 200", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		corrections := make([]CategoryCorrection, 0)
+		for rows.Next() {
+			var cc CategoryCorrection
+			if err := rows.Scan(&cc.ID, &cc.ExpenseID, &cc.Description, &cc.OldCategory, &cc.OldSource, &cc.NewCategory, &cc.CreatedAt); err == nil {
+				corrections = append(corrections, cc)
+			}
+		}
+		c.JSON(http.StatusOK, corrections)
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestMerchantKey(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"Swiggy order #4411 - 12/03", "swiggy order"},
+		{"swiggy order 5120", "swiggy order"},
+		{"  UBER   Trip ", "uber trip"},
+		{"Amazon Pay India Private Limited", "amazon pay india"},
+		{"Netflix.com", "netflix com"},
+		{"2024 1999", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := merchantKey(tt.description); got != tt.want {
+			t.Errorf("merchantKey(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
@@ -6,16 +6,15 @@ import (
 	"time"
 
 	"encoding/json"
+	"errors"
 
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 
 	// Generate a 6-digit OTP
 
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -24,9 +23,10 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
-	"github.com/razorpay/razorpay-go"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -66,6 +66,54 @@ type Expense struct {
 	PaymentStatus string    `json:"payment_status"`
 	Description   string    `json:"description"`
 	Paid          bool      `json:"paid"`
+	PaidAmount    float64   `json:"paid_amount"` // sum of linked payments less refunds, read-only
+
+	CategoryConfidence float64 `json:"category_confidence"` // 1 when set or confirmed by the user
+	CategorySource     string  `json:"category_source"`     // user, rule, ml or fallback
+
+	AccountID *int     `json:"account_id"` // account it is paid from, if known
+	GroupID   *int     `json:"group_id"`   // group sharing the expense; the user is the member who paid
+	Tags      []string `json:"tags"`
+}
+
+// expenseColumns selects an expense row in the order expected by scanExpense.
+// The paid amount is net of refunds that have not failed.
+const expenseColumns = "id, user_id, date, category, amount, payment_status, description, paid, category_confidence, category_source, account_id, group_id, " +
+	"(SELECT COALESCE(SUM(p.amount), 0) FROM payments p WHERE p.expense_id=expenses.id) - " +
+	"(SELECT COALESCE(SUM(rf.amount), 0) FROM refunds rf JOIN payments p ON p.id=rf.payment_id WHERE p.expense_id=expenses.id AND rf.status<>'failed'), " +
+	"ARRAY(SELECT t.name FROM expense_tags et JOIN tags t ON t.id=et.tag_id WHERE et.expense_id=expenses.id ORDER BY t.name)"
+
+// scanExpense scans a row selected with expenseColumns
+func scanExpense(row pgx.Row) (Expense, error) {
+	var e Expense
+	err := row.Scan(&e.ID, &e.UserID, &e.Date, &e.Category, &e.Amount, &e.PaymentStatus, &e.Description, &e.Paid,
+		&e.CategoryConfidence, &e.CategorySource, &e.AccountID, &e.GroupID, &e.PaidAmount, &e.Tags)
+	return e, err
+}
+
+// insertExpense inserts exp and its tags for userID, setting its ID and UserID. The account, if
+// any, must be the user's.
+func insertExpense(ctx context.Context, q querier, userID int, exp *Expense) error {
+	exp.UserID = userID
+	if err := checkAccount(ctx, q, userID, exp.AccountID); err != nil {
+		return err
+	}
+	err := q.QueryRow(ctx,
+		"INSERT INTO expenses (user_id, date, category, amount, payment_status, description, paid, category_confidence, category_source, account_id, group_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id",
+		userID, exp.Date, exp.Category, exp.Amount, exp.PaymentStatus, exp.Description, exp.Paid, exp.CategoryConfidence, exp.CategorySource, exp.AccountID, exp.GroupID).Scan(&exp.ID)
+	if err != nil || len(exp.Tags) == 0 {
+		return err
+	}
+	exp.Tags, err = setTags(ctx, q, userID, expenseTagLinks, exp.ID, exp.Tags)
+	return err
+}
+
+// Outstanding is the amount still owed on the expense, never negative
+func (e Expense) Outstanding() float64 {
+	if e.PaidAmount >= e.Amount {
+		return 0
+	}
+	return roundMoney(e.Amount - e.PaidAmount)
 }
 
 // MarshalJSON for Expense to format Date as YYYY-MM-DD using encoding/json
@@ -79,6 +127,15 @@ func (e Expense) MarshalJSON() ([]byte, error) {
 		PaymentStatus string  `json:"payment_status"`
 		Description   string  `json:"description"`
 		Paid          bool    `json:"paid"`
+		PaidAmount    float64 `json:"paid_amount"`
+		Outstanding   float64 `json:"outstanding"`
+
+		CategoryConfidence float64 `json:"category_confidence"`
+		CategorySource     string  `json:"category_source"`
+
+		AccountID *int     `json:"account_id"`
+		GroupID   *int     `json:"group_id"`
+		Tags      []string `json:"tags"`
 	}{
 		ID:            e.ID,
 		UserID:        e.UserID,
@@ -88,17 +145,68 @@ func (e Expense) MarshalJSON() ([]byte, error) {
 		PaymentStatus: e.PaymentStatus,
 		Description:   e.Description,
 		Paid:          e.Paid,
+		PaidAmount:    e.PaidAmount,
+		Outstanding:   e.Outstanding(),
+
+		CategoryConfidence: e.CategoryConfidence,
+		CategorySource:     e.CategorySource,
+
+		AccountID: e.AccountID,
+		GroupID:   e.GroupID,
+		Tags:      nonNilTags(e.Tags),
 	})
 }
 
+// expenseInput is the request body accepted when creating or importing an expense
+type expenseInput struct {
+	Date          string   `json:"date"`
+	Category      string   `json:"category"`
+	Amount        float64  `json:"amount"`
+	PaymentStatus string   `json:"payment_status"`
+	Description   string   `json:"description"`
+	Paid          bool     `json:"paid"`
+	AccountID     *int     `json:"account_id"`
+	Tags          []string `json:"tags"`
+}
+
+// toExpense validates the input and fills in defaults for date and payment status
+func (in expenseInput) toExpense() (Expense, error) {
+	var exp Expense
+	exp.Category = in.Category
+	exp.Amount = in.Amount
+	exp.PaymentStatus = in.PaymentStatus
+	exp.Description = in.Description
+	exp.Paid = in.Paid
+	exp.AccountID = in.AccountID
+	exp.Tags = in.Tags
+	// Parse date string
+	if in.Date != "" {
+		t, err := time.Parse("2006-01-02", in.Date)
+		if err != nil {
+			return exp, errors.New("Invalid date format. Use YYYY-MM-DD.")
+		}
+		exp.Date = t
+	} else {
+		exp.Date = time.Now()
+	}
+	if exp.PaymentStatus == "" {
+		exp.PaymentStatus = "Unpaid"
+	}
+	return exp, nil
+}
+
 type Payment struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	PaymentDate time.Time `json:"payment_date"`
-	Amount      float64   `json:"amount"`
-	ExpenseID   *int      `json:"expense_id"`
-	Category    *string   `json:"category,omitempty"`
-	Description *string   `json:"description,omitempty"`
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	PaymentDate  time.Time `json:"payment_date"`
+	Amount       float64   `json:"amount"`
+	ExpenseID    *int      `json:"expense_id"`
+	Category     *string   `json:"category,omitempty"`
+	Description  *string   `json:"description,omitempty"`
+	AccountID    *int      `json:"account_id"`    // account it was paid from, if known
+	StatementID  *int      `json:"statement_id"`  // card statement it pays, if any
+	SettlementID *int      `json:"settlement_id"` // settle-up transfer it pays, if any
+	Tags         []string  `json:"tags"`
 }
 
 // MarshalJSON for Payment to format PaymentDate as YYYY-MM-DD and handle nullable ExpenseID, Category, Description
@@ -122,21 +230,29 @@ func (p Payment) MarshalJSON() ([]byte, error) {
 		description = ""
 	}
 	return json.Marshal(&struct {
-		ID          int         `json:"id"`
-		UserID      int         `json:"user_id"`
-		PaymentDate string      `json:"payment_date"`
-		Amount      float64     `json:"amount"`
-		ExpenseID   interface{} `json:"expense_id"`
-		Category    string      `json:"category,omitempty"`
-		Description string      `json:"description,omitempty"`
+		ID           int         `json:"id"`
+		UserID       int         `json:"user_id"`
+		PaymentDate  string      `json:"payment_date"`
+		Amount       float64     `json:"amount"`
+		ExpenseID    interface{} `json:"expense_id"`
+		Category     string      `json:"category,omitempty"`
+		Description  string      `json:"description,omitempty"`
+		AccountID    *int        `json:"account_id"`
+		StatementID  *int        `json:"statement_id,omitempty"`
+		SettlementID *int        `json:"settlement_id,omitempty"`
+		Tags         []string    `json:"tags"`
 	}{
-		ID:          p.ID,
-		UserID:      p.UserID,
-		PaymentDate: p.PaymentDate.Format("2006-01-02"),
-		Amount:      p.Amount,
-		ExpenseID:   expenseID,
-		Category:    category,
-		Description: description,
+		ID:           p.ID,
+		UserID:       p.UserID,
+		PaymentDate:  p.PaymentDate.Format("2006-01-02"),
+		Amount:       p.Amount,
+		ExpenseID:    expenseID,
+		Category:     category,
+		Description:  description,
+		AccountID:    p.AccountID,
+		StatementID:  p.StatementID,
+		SettlementID: p.SettlementID,
+		Tags:         nonNilTags(p.Tags),
 	})
 }
 
@@ -171,6 +287,26 @@ func initDB() error {
 	}
 	return db.Ping(context.Background())
 }
+
+// querier is satisfied by both the connection pool and a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// withTx runs fn inside a transaction, committing if fn returns nil and rolling back otherwise
+func withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
@@ -212,6 +348,17 @@ func authMiddleware() gin.HandlerFunc {
 
 func main() {
 	_ = godotenv.Load()
+	cfg = loadConfig()
+	var err error
+	if gateway, err = newPaymentGateway(cfg); err != nil {
+		fmt.Println("[CONFIG] Payment gateway disabled:", err)
+	} else {
+		fmt.Println("[CONFIG] Payment gateway:", gateway.Name())
+	}
+	categorizer = newCategorizerClient(cfg.AIServiceURL, cfg.AIServiceTimeout, cfg.AIServiceRetries, cfg.AICacheTTL, cfg.AIBreakerFailures, cfg.AIBreakerCooldown)
+	if chatLLM, err = newLLMClient(cfg); err != nil {
+		fmt.Println("[CONFIG] Chatbot LLM fallback disabled:", err)
+	}
 	if err := initDB(); err != nil {
 		fmt.Println("[DB ERROR] Failed to connect to database:", err)
 		os.Exit(1)
@@ -227,29 +374,6 @@ func main() {
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
-	// AI Categorization endpoint (calls external Python AI microservice)
-	r.POST("/api/ai/categorize", func(c *gin.Context) {
-		var req struct {
-			Description string `json:"description"`
-		}
-		if err := c.ShouldBindJSON(&req); err != nil || req.Description == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Description required"})
-			return
-		}
-		// Call Python AI service (assumes running at http://localhost:8001/categorize)
-		aiReqBody := []byte(fmt.Sprintf(`{"description": %q}`, req.Description))
-		//% resp, err := http.Post("http://localhost:8001/categorize", "application/json", bytes.NewBuffer(aiReqBody))
-		resp, err := http.Post("http://localhost:8001/categorize", "application/json", bytes.NewBuffer(aiReqBody))
-
-		if err != nil || resp.StatusCode != 200 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service unavailable"})
-			return
-		}
-		defer resp.Body.Close()
-		body, _ := io.ReadAll(resp.Body)
-		c.Data(http.StatusOK, "application/json", body)
-	})
-
 	// Resend OTP endpoint (now after r is defined)
 	r.POST("/api/resend-otp", func(c *gin.Context) {
 		var req struct {
@@ -259,25 +383,29 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
-		var verified bool
-		err := db.QueryRow(context.Background(), "SELECT verified FROM users WHERE email=$1", req.Email).Scan(&verified)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
-			return
-		}
-		if verified {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User already verified"})
-			return
-		}
 		otp, err := generateOTP()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
 			return
 		}
-		otpExpires := time.Now().Add(10 * time.Minute)
-		_, err = db.Exec(context.Background(), "UPDATE users SET otp_code=$1, otp_expires_at=$2 WHERE email=$3", otp, otpExpires, req.Email)
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			var verified bool
+			err := tx.QueryRow(ctx, "SELECT verified FROM users WHERE email=$1 FOR UPDATE", req.Email).Scan(&verified)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusBadRequest, "User not found")
+			}
+			if err != nil {
+				return err
+			}
+			if verified {
+				return newAPIError(http.StatusBadRequest, "User already verified")
+			}
+			_, err = tx.Exec(ctx, "UPDATE users SET otp_code=$1, otp_expires_at=$2 WHERE email=$3", otp, time.Now().Add(10*time.Minute), req.Email)
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update OTP"})
+			respondError(c, err, "Failed to update OTP")
 			return
 		}
 		if err := sendOTPEmail(req.Email, otp); err != nil {
@@ -310,17 +438,6 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Username, email, and password required"})
 			return
 		}
-		// Check if user exists (by username or email)
-		var exists bool
-		err := db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM users WHERE name=$1 OR email=$2)", req.Username, req.Email).Scan(&exists)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
-			return
-		}
-		if exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email already exists"})
-			return
-		}
 		hash, err := hashPassword(req.Password)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
@@ -331,10 +448,27 @@ func main() {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
 			return
 		}
-		otpExpires := time.Now().Add(10 * time.Minute)
-		_, err = db.Exec(context.Background(), "INSERT INTO users (name, email, password_hash, otp_code, otp_expires_at, verified) VALUES ($1, $2, $3, $4, $5, $6)", req.Username, req.Email, hash, otp, otpExpires, false)
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			// Check if user exists (by username or email)
+			var exists bool
+			err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE name=$1 OR email=$2)", req.Username, req.Email).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return newAPIError(http.StatusBadRequest, "Username or email already exists")
+			}
+			otpExpires := time.Now().Add(10 * time.Minute)
+			_, err = tx.Exec(ctx, "INSERT INTO users (name, email, password_hash, otp_code, otp_expires_at, verified) VALUES ($1, $2, $3, $4, $5, $6)", req.Username, req.Email, hash, otp, otpExpires, false)
+			// A concurrent registration can get past the check; the unique indexes catch it
+			if isUniqueViolation(err) {
+				return newAPIError(http.StatusBadRequest, "Username or email already exists")
+			}
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			respondError(c, err, "Failed to create user")
 			return
 		}
 		// Send OTP email
@@ -355,25 +489,27 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
-		var dbOTP string
-		var expiresAt time.Time
-		err := db.QueryRow(context.Background(), "SELECT otp_code, otp_expires_at FROM users WHERE email=$1", req.Email).Scan(&dbOTP, &expiresAt)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email or OTP"})
-			return
-		}
-		if dbOTP != req.OTP {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect OTP"})
-			return
-		}
-		if time.Now().After(expiresAt) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "OTP expired"})
-			return
-		}
-		// Mark user as verified and clear OTP fields
-		_, err = db.Exec(context.Background(), "UPDATE users SET verified=true, otp_code=NULL, otp_expires_at=NULL WHERE email=$1", req.Email)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			// Locking the row stops a resent OTP from landing between the check and the update
+			var dbOTP string
+			var expiresAt time.Time
+			err := tx.QueryRow(ctx, "SELECT otp_code, otp_expires_at FROM users WHERE email=$1 FOR UPDATE", req.Email).Scan(&dbOTP, &expiresAt)
+			if err != nil {
+				return newAPIError(http.StatusBadRequest, "Invalid email or OTP")
+			}
+			if dbOTP != req.OTP {
+				return newAPIError(http.StatusUnauthorized, "Incorrect OTP")
+			}
+			if time.Now().After(expiresAt) {
+				return newAPIError(http.StatusUnauthorized, "OTP expired")
+			}
+			// Mark user as verified and clear OTP fields
+			_, err = tx.Exec(ctx, "UPDATE users SET verified=true, otp_code=NULL, otp_expires_at=NULL WHERE email=$1", req.Email)
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user"})
+			respondError(c, err, "Failed to verify user")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully!"})
@@ -477,53 +613,75 @@ func main() {
 			return
 		}
 
-		// Check for email/username uniqueness if changed
-		if req.Username != nil && *req.Username != "" {
-			var exists bool
-			err := db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM users WHERE name=$1 AND id<>$2)", *req.Username, userID).Scan(&exists)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
-				return
-			}
-			if exists {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Username already taken"})
-				return
-			}
-		}
-		if req.Email != nil && *req.Email != "" {
-			var exists bool
-			err := db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM users WHERE email=$1 AND id<>$2)", *req.Email, userID).Scan(&exists)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
-				return
+		// Uniqueness checks and the update run in one transaction so they see the same state
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			// Check for email/username uniqueness if changed
+			if req.Username != nil && *req.Username != "" {
+				var exists bool
+				err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE name=$1 AND id<>$2)", *req.Username, userID).Scan(&exists)
+				if err != nil {
+					return err
+				}
+				if exists {
+					return newAPIError(http.StatusBadRequest, "Username already taken")
+				}
 			}
-			if exists {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Email already taken"})
-				return
+			if req.Email != nil && *req.Email != "" {
+				var exists bool
+				err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email=$1 AND id<>$2)", *req.Email, userID).Scan(&exists)
+				if err != nil {
+					return err
+				}
+				if exists {
+					return newAPIError(http.StatusBadRequest, "Email already taken")
+				}
 			}
-		}
 
-		// Build final query
-		query := "UPDATE users SET " + strings.Join(updates, ", ") + " WHERE id=$" + strconv.Itoa(argIdx)
-		args = append(args, userID)
-		_, err := db.Exec(context.Background(), query, args...)
+			// Build final query
+			query := "UPDATE users SET " + strings.Join(updates, ", ") + " WHERE id=$" + strconv.Itoa(argIdx)
+			args = append(args, userID)
+			_, err := tx.Exec(ctx, query, args...)
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			respondError(c, err, "Failed to update profile")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Profile updated"})
 	})
 
-	// Get current user's budget
+	// Get current user's budget, with this month's income, expenses and savings against it
 	auth.GET("/user/budget", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
+		ctx := context.Background()
 		var budget float64
-		err := db.QueryRow(context.Background(), "SELECT budget FROM users WHERE id=$1", userID).Scan(&budget)
+		err := db.QueryRow(ctx, "SELECT budget FROM users WHERE id=$1", userID).Scan(&budget)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budget"})
+			return
+		}
+		from := monthStart(time.Now())
+		to := from.AddDate(0, 1, -1)
+		income, _, err := sumIncome(ctx, userID, from, to)
+		var expenses float64
+		if err == nil {
+			expenses, err = sumExpenses(ctx, userID, from, to.AddDate(0, 0, 1))
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budget"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"budget": budget})
+		netCashFlow, savingsRate := cashFlow(income, expenses)
+		c.JSON(http.StatusOK, gin.H{
+			"budget":        budget,
+			"month":         from.Format("2006-01"),
+			"income":        income,
+			"expenses":      expenses,
+			"remaining":     roundMoney(budget - expenses),
+			"net_cash_flow": netCashFlow,
+			"savings_rate":  savingsRate,
+		})
 	})
 
 	// Set current user's budget
@@ -544,44 +702,16 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"message": "Budget updated", "budget": req.Budget})
 	})
 
-	// Razorpay order creation endpoint
-	auth.POST("/razorpay/order", func(c *gin.Context) {
+	auth.GET("/expenses", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
-		var req struct {
-			Amount   int64  `json:"amount"`
-			Currency string `json:"currency"`
-		}
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-			return
-		}
-		if req.Amount <= 0 || req.Currency == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount and currency required"})
-			return
-		}
-
-		// Razorpay client (test keys for development)
-		key := "rzp_test_R5ZEYHFFHdm7dx"     // Replace with your actual test key ID
-		secret := "OHOSxJ363b2d0MAoLg8U8X1I" // Replace with your actual test key secret
-		client := razorpay.NewClient(key, secret)
-
-		data := map[string]interface{}{
-			"amount":          req.Amount, // amount in paise
-			"currency":        req.Currency,
-			"receipt":         "user-" + strconv.Itoa(userID) + "-" + strconv.FormatInt(time.Now().Unix(), 10),
-			"payment_capture": 1,
-		}
-		order, err := client.Order.Create(data, nil)
+		// ?tag= may be repeated; only expenses carrying every tag are listed
+		tags, err := normalizeTags(c.QueryArray("tag"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Razorpay order", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, order)
-	})
-
-	auth.GET("/expenses", func(c *gin.Context) {
-		userID := getUserIDFromToken(c)
-		rows, err := db.Query(context.Background(), "SELECT id, user_id, date, category, amount, payment_status, description, paid FROM expenses WHERE user_id=$1", userID)
+		rows, err := db.Query(context.Background(),
+			"SELECT "+expenseColumns+" FROM expenses WHERE user_id=$1 AND "+hasAllTags(expenseTagLinks, "expenses.id", "$2"), userID, tags)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
 			return
@@ -589,8 +719,7 @@ func main() {
 		defer rows.Close()
 		userExpenses := make([]Expense, 0)
 		for rows.Next() {
-			var exp Expense
-			if err := rows.Scan(&exp.ID, &exp.UserID, &exp.Date, &exp.Category, &exp.Amount, &exp.PaymentStatus, &exp.Description, &exp.Paid); err == nil {
+			if exp, err := scanExpense(rows); err == nil {
 				userExpenses = append(userExpenses, exp)
 			}
 		}
@@ -599,51 +728,54 @@ func main() {
 
 	auth.POST("/expenses", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
-		var input struct {
-			Date          string  `json:"date"`
-			Category      string  `json:"category"`
-			Amount        float64 `json:"amount"`
-			PaymentStatus string  `json:"payment_status"`
-			Description   string  `json:"description"`
-			Paid          bool    `json:"paid"`
-		}
+		var input expenseInput
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		var exp Expense
-		exp.Category = input.Category
-		exp.Amount = input.Amount
-		exp.PaymentStatus = input.PaymentStatus
-		exp.Description = input.Description
-		exp.Paid = input.Paid
-		// Parse date string
-		if input.Date != "" {
-			t, err := time.Parse("2006-01-02", input.Date)
+		exp, err := input.toExpense()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Refuse likely duplicates unless the client explicitly overrides with ?force=true
+		if c.Query("force") != "true" {
+			dups, err := findDuplicateExpenses(context.Background(), db, userID, exp, 0)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates"})
+				return
+			}
+			if len(dups) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Possible duplicate expense", "duplicates": dups})
 				return
 			}
-			exp.Date = t
-		} else {
-			exp.Date = time.Now()
 		}
-		if exp.PaymentStatus == "" {
-			exp.PaymentStatus = "Unpaid"
+		if err := categorizeExpense(c.Request.Context(), userID, &exp); err != nil {
+			respondError(c, err, "Failed to categorize expense")
+			return
 		}
-		err := db.QueryRow(context.Background(),
-			"INSERT INTO expenses (user_id, date, category, amount, payment_status, description, paid) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
-			userID, exp.Date, exp.Category, exp.Amount, exp.PaymentStatus, exp.Description, exp.Paid).Scan(&exp.ID)
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			return insertExpense(ctx, tx, userID, &exp)
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add expense"})
+			respondError(c, err, "Failed to add expense")
 			return
 		}
-		exp.UserID = userID
+		checkAnomalies(ctx, userID, expenseSubject(exp))
 		c.JSON(http.StatusCreated, exp)
 	})
 	auth.GET("/payments", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
-		rows, err := db.Query(context.Background(), "SELECT id, user_id, payment_date, amount, expense_id, category, description FROM payments WHERE user_id=$1", userID)
+		tags, err := normalizeTags(c.QueryArray("tag"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rows, err := db.Query(context.Background(),
+			"SELECT id, user_id, payment_date, amount, expense_id, category, description, account_id, statement_id, settlement_id, "+
+				"ARRAY(SELECT t.name FROM payment_tags pt JOIN tags t ON t.id=pt.tag_id WHERE pt.payment_id=payments.id ORDER BY t.name) "+
+				"FROM payments WHERE user_id=$1 AND "+hasAllTags(paymentTagLinks, "payments.id", "$2"), userID, tags)
 		// rows, err := db.Query(context.Background(), "SELECT id, user_id, payment_date, amount, expense_id, category, description FROM payments", userID)
 
 		if err != nil {
@@ -655,7 +787,7 @@ func main() {
 		i := 0
 		for rows.Next() {
 			var pay Payment
-			if err := rows.Scan(&pay.ID, &pay.UserID, &pay.PaymentDate, &pay.Amount, &pay.ExpenseID, &pay.Category, &pay.Description); err == nil {
+			if err := rows.Scan(&pay.ID, &pay.UserID, &pay.PaymentDate, &pay.Amount, &pay.ExpenseID, &pay.Category, &pay.Description, &pay.AccountID, &pay.StatementID, &pay.SettlementID, &pay.Tags); err == nil {
 				// If payment is linked to an expense, override category and description from expense
 				if pay.ExpenseID != nil {
 					var category, description string
@@ -678,16 +810,27 @@ func main() {
 	auth.POST("/payments", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
 		var input struct {
-			PaymentDate string  `json:"payment_date"`
-			Amount      float64 `json:"amount"`
-			ExpenseID   *int    `json:"expense_id"`
-			Category    string  `json:"category"`
-			Description string  `json:"description"`
+			PaymentDate string   `json:"payment_date"`
+			Amount      float64  `json:"amount"`
+			ExpenseID   *int     `json:"expense_id"`
+			StatementID *int     `json:"statement_id"` // pays a credit card statement instead of an expense
+			Category    string   `json:"category"`
+			Description string   `json:"description"`
+			AccountID   *int     `json:"account_id"`
+			Tags        []string `json:"tags"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if input.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+			return
+		}
+		if input.ExpenseID != nil && input.StatementID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A payment can be linked to an expense or a statement, not both"})
+			return
+		}
 		var paymentDate time.Time
 		var err error
 		if input.PaymentDate != "" {
@@ -703,6 +846,8 @@ func main() {
 		pay.PaymentDate = paymentDate
 		pay.Amount = input.Amount
 		pay.ExpenseID = input.ExpenseID
+		pay.StatementID = input.StatementID
+		pay.AccountID = input.AccountID
 		if input.Category != "" {
 			pay.Category = &input.Category
 		} else {
@@ -713,54 +858,135 @@ func main() {
 		} else {
 			pay.Description = nil
 		}
-		if pay.ExpenseID != nil {
-			err := db.QueryRow(context.Background(),
-				"INSERT INTO payments (user_id, payment_date, amount, expense_id) VALUES ($1, $2, $3, $4) RETURNING id",
-				userID, paymentDate, input.Amount, *input.ExpenseID).Scan(&pay.ID)
-			if err != nil {
-				// fmt.Printf("[ERROR] Failed to add payment (linked): %v\n", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add payment", "details": err.Error()})
-				return
+		ctx := context.Background()
+		// tagPayment sets the requested tags once the payment row exists
+		tagPayment := func(tx pgx.Tx) (err error) {
+			pay.Tags, err = setTags(ctx, tx, userID, paymentTagLinks, pay.ID, input.Tags)
+			return err
+		}
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			if err := checkAccount(ctx, tx, userID, pay.AccountID); err != nil {
+				return err
+			}
+			if pay.StatementID != nil {
+				// A card bill isn't spending of its own: the expenses on the statement already are
+				s, err := scanStatement(tx.QueryRow(ctx, "SELECT "+statementColumns+" FROM card_statements WHERE id=$1 AND user_id=$2", *pay.StatementID, userID))
+				if errors.Is(err, pgx.ErrNoRows) {
+					return newAPIError(http.StatusNotFound, "Statement not found")
+				}
+				if err != nil {
+					return err
+				}
+				if pay.AccountID != nil && *pay.AccountID == s.AccountID {
+					return newAPIError(http.StatusBadRequest, "A card statement can't be paid from the card itself")
+				}
+				// Bring the statement up to date before paying it; this also serialises payments on the card
+				card, err := loadOwnedAccount(ctx, tx, s.AccountID, userID)
+				if err != nil {
+					return err
+				}
+				if err := syncStatements(ctx, tx, userID, card); err != nil {
+					return err
+				}
+				if _, err := lockOwnedStatement(ctx, tx, s.ID, userID); err != nil {
+					return err
+				}
+				err = tx.QueryRow(ctx,
+					"INSERT INTO payments (user_id, payment_date, amount, statement_id, description, account_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+					userID, paymentDate, input.Amount, s.ID, pay.Description, pay.AccountID).Scan(&pay.ID)
+				if err != nil {
+					return err
+				}
+				if _, err := reconcileStatement(ctx, tx, s.ID, userID); err != nil {
+					return err
+				}
+				pay.Category = nil
+				return tagPayment(tx)
+			}
+			if pay.ExpenseID == nil {
+				category, err := resolveCategory(ctx, tx, userID, input.Category)
+				if err != nil {
+					return err
+				}
+				if category != "" {
+					input.Category = category
+					pay.Category = &input.Category
+				}
+				// Manual payment: persist category and description in DB
+				err = tx.QueryRow(ctx,
+					"INSERT INTO payments (user_id, payment_date, amount, expense_id, category, description, account_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+					userID, paymentDate, input.Amount, nil, input.Category, input.Description, pay.AccountID).Scan(&pay.ID)
+				if err != nil {
+					return err
+				}
+				return tagPayment(tx)
 			}
-			// Mark expense as paid
-			_, err = db.Exec(context.Background(), "UPDATE expenses SET paid=TRUE WHERE id=$1 AND user_id=$2", *input.ExpenseID, userID)
+			// The linked expense must belong to the caller; locking it also serialises concurrent payments
+			linked, err := lockOwnedExpense(ctx, tx, *input.ExpenseID, userID)
 			if err != nil {
-				// fmt.Printf("[ERROR] Failed to update expense as paid: %v\n", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense as paid", "details": err.Error()})
-				return
+				return err
 			}
-			// Fetch category and description from expense for response
-			var category, description string
-			err = db.QueryRow(context.Background(), "SELECT category, description FROM expenses WHERE id=$1", *input.ExpenseID).Scan(&category, &description)
-			if err == nil {
-				pay.Category = &category
-				pay.Description = &description
+			// Without an account of its own the payment comes from the expense's account
+			if pay.AccountID == nil {
+				pay.AccountID = linked.AccountID
 			}
-		} else {
-			// Manual payment: persist category and description in DB
-			err := db.QueryRow(context.Background(),
-				"INSERT INTO payments (user_id, payment_date, amount, expense_id, category, description) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-				userID, paymentDate, input.Amount, nil, input.Category, input.Description).Scan(&pay.ID)
+			err = tx.QueryRow(ctx,
+				"INSERT INTO payments (user_id, payment_date, amount, expense_id, account_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+				userID, paymentDate, input.Amount, *input.ExpenseID, pay.AccountID).Scan(&pay.ID)
 			if err != nil {
-				// fmt.Printf("[ERROR] Failed to add payment (manual): %v\n", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add payment", "details": err.Error()})
-				return
+				return err
 			}
+			// Recompute the expense's status from all of its payments, so partial payments are tracked
+			exp, err := reconcileExpense(ctx, tx, *input.ExpenseID, userID)
+			if err != nil {
+				return err
+			}
+			// Category and description come from the expense for response
+			pay.Category = &exp.Category
+			pay.Description = &exp.Description
+			return tagPayment(tx)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add payment")
+			return
 		}
 		pay.UserID = userID
+		// Payments against an expense were checked with the expense itself, and card bills are not spending
+		if pay.ExpenseID == nil && pay.StatementID == nil {
+			checkAnomalies(ctx, userID, anomalySubject{Type: "payment", ID: pay.ID, Category: input.Category, Amount: pay.Amount, Date: pay.PaymentDate})
+		}
 		c.JSON(http.StatusCreated, pay)
 	})
 	// Delete payment (DELETE)
 	auth.DELETE("/payments/:id", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
 		idParam := c.Param("id")
-		res, err := db.Exec(context.Background(), "DELETE FROM payments WHERE id=$1 AND user_id=$2", atoi(idParam), userID)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var expenseID, statementID, settlementID *int
+			err := tx.QueryRow(ctx, "DELETE FROM payments WHERE id=$1 AND user_id=$2 RETURNING expense_id, statement_id, settlement_id", atoi(idParam), userID).
+				Scan(&expenseID, &statementID, &settlementID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Payment not found")
+			}
+			if err != nil {
+				return err
+			}
+			// Removing a payment can take the linked expense or statement back to Partially Paid or Unpaid
+			if expenseID != nil {
+				_, err = reconcileExpense(ctx, tx, *expenseID, userID)
+			}
+			if err == nil && statementID != nil {
+				_, err = reconcileStatement(ctx, tx, *statementID, userID)
+			}
+			// Without its payment a settle-up transfer never happened
+			if err == nil && settlementID != nil {
+				_, err = tx.Exec(ctx, "UPDATE settlements SET status=$1 WHERE id=$2", settlementCancelled, *settlementID)
+			}
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payment"})
-			return
-		}
-		if res.RowsAffected() == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			respondError(c, err, "Failed to delete payment")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
@@ -774,19 +1000,67 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		res, err := db.Exec(context.Background(),
-			"UPDATE expenses SET date=$1, category=$2, amount=$3, description=$4 WHERE id=$5 AND user_id=$6",
-			updated.Date, updated.Category, updated.Amount, updated.Description, atoi(idParam), userID)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			prev, err := lockOwnedExpense(ctx, tx, atoi(idParam), userID)
+			if err != nil {
+				return err
+			}
+			if updated.Amount != prev.Amount {
+				// The amount claimed in a submitted expense report is what the approver sees
+				claimed, err := expenseClaimed(ctx, tx, prev.ID)
+				if err != nil {
+					return err
+				}
+				if claimed {
+					return newAPIError(http.StatusConflict, "Expense is in a submitted expense report")
+				}
+			}
+			if updated.Category, err = resolveCategory(ctx, tx, userID, updated.Category); err != nil {
+				return err
+			}
+			if err := checkAccount(ctx, tx, userID, updated.AccountID); err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx,
+				// An omitted account_id keeps the current account
+				"UPDATE expenses SET date=$1, category=$2, amount=$3, description=$4, account_id=COALESCE($7, account_id), "+
+					// Changing the category makes it the user's choice
+					"category_confidence=CASE WHEN category=$2 THEN category_confidence ELSE 1 END, "+
+					"category_source=CASE WHEN category=$2 THEN category_source ELSE 'user' END "+
+					"WHERE id=$5 AND user_id=$6",
+				updated.Date, updated.Category, updated.Amount, updated.Description, prev.ID, userID, updated.AccountID)
+			if err != nil {
+				return err
+			}
+			if updated.Category != "" && updated.Category != prev.Category {
+				prev.Description = updated.Description
+				if err := recordCategoryCorrection(ctx, tx, userID, prev, updated.Category); err != nil {
+					return err
+				}
+			}
+			// A new amount changes how much is outstanding on an expense that has payments, and
+			// each participant's share of a split expense
+			exp, err := lockOwnedExpense(ctx, tx, prev.ID, userID)
+			if err != nil {
+				return err
+			}
+			if exp.Amount != prev.Amount {
+				if err := resplitExpense(ctx, tx, exp); err != nil {
+					return err
+				}
+			}
+			if !statusNeedsReconcile(prev, exp) {
+				updated = exp
+				return nil
+			}
+			updated, err = reconcileExpense(ctx, tx, exp.ID, userID)
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense"})
+			respondError(c, err, "Failed to update expense")
 			return
 		}
-		if res.RowsAffected() == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
-			return
-		}
-		updated.ID = atoi(idParam)
-		updated.UserID = userID
 		c.JSON(http.StatusOK, updated)
 	})
 
@@ -794,13 +1068,24 @@ func main() {
 	auth.DELETE("/expenses/:id", func(c *gin.Context) {
 		userID := getUserIDFromToken(c)
 		idParam := c.Param("id")
-		res, err := db.Exec(context.Background(), "DELETE FROM expenses WHERE id=$1 AND user_id=$2", atoi(idParam), userID)
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			exp, err := lockOwnedExpense(ctx, tx, atoi(idParam), userID)
+			if err != nil {
+				return err
+			}
+			claimed, err := expenseClaimed(ctx, tx, exp.ID)
+			if err != nil {
+				return err
+			}
+			if claimed {
+				return newAPIError(http.StatusConflict, "Expense is in a submitted expense report")
+			}
+			_, err = tx.Exec(ctx, "DELETE FROM expenses WHERE id=$1", exp.ID)
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense"})
-			return
-		}
-		if res.RowsAffected() == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			respondError(c, err, "Failed to delete expense")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
@@ -814,29 +1099,78 @@ func main() {
 			Amount      float64 `json:"amount"`
 			Category    string  `json:"category"`
 			Description string  `json:"description"`
+			AccountID   *int    `json:"account_id"` // omitted keeps the current account
 		}
 		if err := c.ShouldBindJSON(&updated); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		res, err := db.Exec(context.Background(),
-			"UPDATE payments SET amount=$1, category=$2, description=$3 WHERE id=$4 AND user_id=$5",
-			updated.Amount, updated.Category, updated.Description, atoi(idParam), userID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
+		if updated.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
 			return
 		}
-		if res.RowsAffected() == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			if updated.Category, err = resolveCategory(ctx, tx, userID, updated.Category); err != nil {
+				return err
+			}
+			if err := checkAccount(ctx, tx, userID, updated.AccountID); err != nil {
+				return err
+			}
+			var expenseID, statementID *int
+			err = tx.QueryRow(ctx,
+				"UPDATE payments SET amount=$1, category=$2, description=$3, account_id=COALESCE($4, account_id) WHERE id=$5 AND user_id=$6 RETURNING expense_id, statement_id, account_id",
+				updated.Amount, updated.Category, updated.Description, updated.AccountID, atoi(idParam), userID).Scan(&expenseID, &statementID, &updated.AccountID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Payment not found")
+			}
+			if err != nil {
+				return err
+			}
+			if expenseID != nil {
+				_, err = reconcileExpense(ctx, tx, *expenseID, userID)
+			}
+			if err == nil && statementID != nil {
+				_, err = reconcileStatement(ctx, tx, *statementID, userID)
+			}
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update payment")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
 			"amount":      updated.Amount,
 			"category":    updated.Category,
 			"description": updated.Description,
+			"account_id":  updated.AccountID,
 		})
 	})
 
+	registerCategorizerRoutes(auth)
+	registerDuplicateRoutes(auth)
+	registerReconcileRoutes(auth)
+	registerRazorpayRoutes(r, auth)
+	registerRefundRoutes(auth)
+	registerRuleRoutes(auth)
+	registerLearningRoutes(auth)
+	registerCategoryRoutes(auth)
+	registerTagRoutes(auth)
+	registerAnalyticsRoutes(auth)
+	registerIncomeRoutes(auth)
+	registerGoalRoutes(auth)
+	registerAccountRoutes(auth)
+	registerStatementRoutes(auth)
+	registerGroupRoutes(auth)
+	registerSettleUpRoutes(auth)
+	registerForecastRoutes(auth)
+	registerChatbotRoutes(auth)
+	registerParseRoutes(auth)
+	registerAnomalyRoutes(auth)
+	registerNotificationRoutes(auth)
+	registerClaimRoutes(auth)
+
 	r.Run() // listen and serve on 0.0.0.0:8080
 }
 
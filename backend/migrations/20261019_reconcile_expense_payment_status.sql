@@ -0,0 +1,20 @@
+-- Recompute payment_status and paid for expenses that have linked payments.
+-- Previously any linked payment marked the expense paid regardless of amount.
+WITH totals AS (
+    SELECT e.id, e.amount, COALESCE(SUM(p.amount), 0) AS paid_amount
+    FROM expenses e
+    JOIN payments p ON p.expense_id = e.id
+    GROUP BY e.id, e.amount
+)
+UPDATE expenses e
+SET payment_status = CASE
+        WHEN t.paid_amount <= 0 THEN 'Unpaid'
+        WHEN ROUND(t.paid_amount::numeric, 2) < ROUND(t.amount::numeric, 2) THEN 'Partially Paid'
+        WHEN ROUND(t.paid_amount::numeric, 2) = ROUND(t.amount::numeric, 2) THEN 'Paid'
+        ELSE 'Overpaid'
+    END,
+    paid = ROUND(t.paid_amount::numeric, 2) >= ROUND(t.amount::numeric, 2)
+FROM totals t
+WHERE e.id = t.id;
+
+CREATE INDEX IF NOT EXISTS idx_payments_expense_id ON payments (expense_id);
@@ -0,0 +1,10 @@
+-- Gateway payment ID on payments, unique so verify and webhook never record a capture twice
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS razorpay_payment_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_razorpay_payment_id ON payments (razorpay_payment_id);
+
+-- Processed webhook events, for idempotent handling of redeliveries
+CREATE TABLE IF NOT EXISTS razorpay_webhook_events (
+    event_id    TEXT PRIMARY KEY,
+    event_type  TEXT NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,23 @@
+-- Gateway orders created at checkout, and the history of their status changes
+CREATE TABLE IF NOT EXISTS payment_orders (
+    id                  SERIAL PRIMARY KEY,
+    order_id            TEXT NOT NULL UNIQUE,
+    user_id             INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expense_id          INTEGER REFERENCES expenses(id) ON DELETE SET NULL,
+    amount              BIGINT NOT NULL, -- paise
+    currency            TEXT NOT NULL,
+    receipt             TEXT NOT NULL,
+    status              TEXT NOT NULL DEFAULT 'created',
+    razorpay_payment_id TEXT,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_payment_orders_user_id ON payment_orders (user_id);
+
+CREATE TABLE IF NOT EXISTS payment_order_transitions (
+    id               SERIAL PRIMARY KEY,
+    payment_order_id INTEGER NOT NULL REFERENCES payment_orders(id) ON DELETE CASCADE,
+    from_status      TEXT NOT NULL,
+    to_status        TEXT NOT NULL,
+    changed_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
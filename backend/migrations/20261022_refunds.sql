@@ -0,0 +1,12 @@
+-- Refunds of gateway payments. Refunds that have not failed reduce the paid amount of the linked expense.
+CREATE TABLE IF NOT EXISTS refunds (
+    id                SERIAL PRIMARY KEY,
+    payment_id        INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    user_id           INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gateway_refund_id TEXT NOT NULL UNIQUE,
+    amount            DOUBLE PRECISION NOT NULL,
+    status            TEXT NOT NULL DEFAULT 'pending',
+    reason            TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds (payment_id);
@@ -0,0 +1,14 @@
+-- User-defined categorization rules, evaluated by priority (highest first) before the keyword
+-- dictionary and the AI service.
+CREATE TABLE IF NOT EXISTS category_rules (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    match_type TEXT NOT NULL DEFAULT 'keyword' CHECK (match_type IN ('keyword', 'regex')),
+    pattern    TEXT NOT NULL,
+    min_amount DOUBLE PRECISION,
+    max_amount DOUBLE PRECISION,
+    category   TEXT NOT NULL,
+    priority   INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_category_rules_user_id ON category_rules (user_id, priority DESC);
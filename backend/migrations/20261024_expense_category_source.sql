@@ -0,0 +1,4 @@
+-- Where an expense's category came from: the user, a rule, the AI service (ml), or the
+-- keyword fallback used when the AI service is unavailable. Existing categories were user-entered.
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS category_confidence DOUBLE PRECISION NOT NULL DEFAULT 1;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS category_source TEXT NOT NULL DEFAULT 'user';
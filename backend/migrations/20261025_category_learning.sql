@@ -0,0 +1,23 @@
+-- Category corrections made by users, and the per-user merchant mappings learned from them.
+-- A merchant mapping overrides the dictionary and the AI service for that user.
+CREATE TABLE IF NOT EXISTS category_corrections (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expense_id   INTEGER REFERENCES expenses(id) ON DELETE SET NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    old_category TEXT NOT NULL DEFAULT '',
+    old_source   TEXT NOT NULL DEFAULT '',
+    new_category TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_category_corrections_user_id ON category_corrections (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS merchant_mappings (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    merchant   TEXT NOT NULL,
+    category   TEXT NOT NULL,
+    hits       INTEGER NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, merchant)
+);
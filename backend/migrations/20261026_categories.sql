@@ -0,0 +1,12 @@
+-- Per-user categories with an optional parent (Transport > Fuel), colour and icon.
+-- Users are given the default categories, plus names already on their expenses, on first use.
+CREATE TABLE IF NOT EXISTS categories (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    parent_id  INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    color      TEXT NOT NULL DEFAULT '',
+    icon       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_user_name ON categories (user_id, lower(name));
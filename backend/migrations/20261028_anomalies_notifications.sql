@@ -0,0 +1,30 @@
+-- Expenses and payments that stand out from the user's usual amount or frequency for a category
+CREATE TABLE IF NOT EXISTS anomalies (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subject_type TEXT NOT NULL CHECK (subject_type IN ('expense', 'payment')),
+    subject_id   INTEGER NOT NULL,
+    kind         TEXT NOT NULL CHECK (kind IN ('amount', 'frequency')),
+    category     TEXT NOT NULL,
+    amount       DOUBLE PRECISION NOT NULL,
+    baseline     DOUBLE PRECISION NOT NULL,
+    score        DOUBLE PRECISION NOT NULL,
+    explanation  TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (subject_type, subject_id, kind)
+);
+CREATE INDEX IF NOT EXISTS idx_anomalies_user_id ON anomalies (user_id, created_at DESC);
+
+-- In-app notifications, optionally pointing at the row they are about
+CREATE TABLE IF NOT EXISTS notifications (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type        TEXT NOT NULL,
+    message     TEXT NOT NULL,
+    entity_type TEXT NOT NULL DEFAULT '',
+    entity_id   INTEGER,
+    read_at     TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, created_at DESC);
@@ -0,0 +1,13 @@
+-- Income entries. Recurring incomes repeat weekly, monthly or yearly from date until end_date.
+CREATE TABLE IF NOT EXISTS incomes (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date        DATE NOT NULL,
+    amount      DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+    source      TEXT NOT NULL DEFAULT 'other',
+    description TEXT NOT NULL DEFAULT '',
+    recurrence  TEXT NOT NULL DEFAULT '' CHECK (recurrence IN ('', 'weekly', 'monthly', 'yearly')),
+    end_date    DATE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_incomes_user_id ON incomes (user_id, date);
@@ -0,0 +1,24 @@
+-- Savings goals and the contributions recorded towards them. A goal may link a category whose
+-- expenses since start_date also count towards it.
+CREATE TABLE IF NOT EXISTS savings_goals (
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    target_amount DOUBLE PRECISION NOT NULL CHECK (target_amount > 0),
+    deadline      DATE NOT NULL,
+    category      TEXT NOT NULL DEFAULT '',
+    start_date    DATE NOT NULL DEFAULT CURRENT_DATE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_savings_goals_user_id ON savings_goals (user_id);
+
+CREATE TABLE IF NOT EXISTS goal_contributions (
+    id         SERIAL PRIMARY KEY,
+    goal_id    INTEGER NOT NULL REFERENCES savings_goals(id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount     DOUBLE PRECISION NOT NULL,
+    date       DATE NOT NULL DEFAULT CURRENT_DATE,
+    note       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_goal_contributions_goal_id ON goal_contributions (goal_id);
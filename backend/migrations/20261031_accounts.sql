@@ -0,0 +1,37 @@
+-- Accounts money is held in, and transfers between them (which are not spending). Expenses,
+-- payments and incomes may name the account they are paid from or into; a savings goal may
+-- link an account whose balance counts towards it.
+CREATE TABLE IF NOT EXISTS accounts (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    type            TEXT NOT NULL CHECK (type IN ('cash', 'bank', 'credit_card', 'upi', 'wallet')),
+    currency        TEXT NOT NULL DEFAULT 'INR',
+    opening_balance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    opening_date    DATE NOT NULL DEFAULT CURRENT_DATE,
+    archived        BOOLEAN NOT NULL DEFAULT false,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    to_account_id   INTEGER NOT NULL REFERENCES accounts(id),
+    amount          DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+    to_amount       DOUBLE PRECISION NOT NULL CHECK (to_amount > 0), -- in the destination account's currency
+    date            DATE NOT NULL DEFAULT CURRENT_DATE,
+    note            TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (from_account_id <> to_account_id)
+);
+CREATE INDEX IF NOT EXISTS idx_transfers_from_account_id ON transfers (from_account_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_account_id ON transfers (to_account_id);
+
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id);
+ALTER TABLE incomes ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id);
+ALTER TABLE savings_goals ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id);
+CREATE INDEX IF NOT EXISTS idx_expenses_account_id ON expenses (account_id);
+CREATE INDEX IF NOT EXISTS idx_payments_account_id ON payments (account_id);
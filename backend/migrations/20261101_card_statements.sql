@@ -0,0 +1,23 @@
+-- Credit card statement cycles. A card closes its statement on statement_day each month and
+-- the statement falls due on the next due_day; both are clamped to short months. Statements
+-- are grouped from the expenses charged to the card, and payments may be made against one.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS statement_day INTEGER CHECK (statement_day BETWEEN 1 AND 31);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS due_day INTEGER CHECK (due_day BETWEEN 1 AND 31);
+
+CREATE TABLE IF NOT EXISTS card_statements (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id   INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    period_end   DATE NOT NULL, -- closing date
+    due_date     DATE NOT NULL,
+    total        DOUBLE PRECISION NOT NULL DEFAULT 0,
+    paid_amount  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    status       TEXT NOT NULL DEFAULT 'Open',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (account_id, period_end)
+);
+CREATE INDEX IF NOT EXISTS idx_card_statements_user_id ON card_statements (user_id);
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS statement_id INTEGER REFERENCES card_statements(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_payments_statement_id ON payments (statement_id);
@@ -0,0 +1,42 @@
+-- Groups of users sharing expenses, such as a flat or a trip. A group expense belongs to the
+-- member who paid it and is split between participants; expense_splits records what each owes.
+CREATE TABLE IF NOT EXISTS groups (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id  INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id   INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role      TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'member')),
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
+
+-- Invitations go to an email so people can be invited before they sign up
+CREATE TABLE IF NOT EXISTS group_invitations (
+    id           SERIAL PRIMARY KEY,
+    group_id     INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    email        TEXT NOT NULL,
+    invited_by   INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    responded_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_invitations_pending ON group_invitations (group_id, lower(email)) WHERE status = 'pending';
+
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES groups(id);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS split_method TEXT CHECK (split_method IN ('equal', 'exact', 'percentage', 'shares'));
+CREATE INDEX IF NOT EXISTS idx_expenses_group_id ON expenses (group_id);
+
+CREATE TABLE IF NOT EXISTS expense_splits (
+    expense_id INTEGER NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users(id),
+    value      DOUBLE PRECISION, -- exact amount, percentage or shares as given; NULL for equal splits
+    amount     DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (expense_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_expense_splits_user_id ON expense_splits (user_id);
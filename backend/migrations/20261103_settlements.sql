@@ -0,0 +1,22 @@
+-- Settle-up transfers paying back what people owe for split expenses, in a group or between
+-- two people outside groups. Each is recorded as a Payment by the person paying; one paid
+-- online is pending until its gateway order is paid.
+CREATE TABLE IF NOT EXISTS settlements (
+    id           SERIAL PRIMARY KEY,
+    group_id     INTEGER REFERENCES groups(id),
+    from_user_id INTEGER NOT NULL REFERENCES users(id),
+    to_user_id   INTEGER NOT NULL REFERENCES users(id),
+    amount       DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled')),
+    created_by   INTEGER NOT NULL REFERENCES users(id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ,
+    CHECK (from_user_id <> to_user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_settlements_group_id ON settlements (group_id);
+CREATE INDEX IF NOT EXISTS idx_settlements_from_user_id ON settlements (from_user_id);
+CREATE INDEX IF NOT EXISTS idx_settlements_to_user_id ON settlements (to_user_id);
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS settlement_id INTEGER REFERENCES settlements(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_payments_settlement_id ON payments (settlement_id);
+ALTER TABLE payment_orders ADD COLUMN IF NOT EXISTS settlement_id INTEGER REFERENCES settlements(id) ON DELETE SET NULL;
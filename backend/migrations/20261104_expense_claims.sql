@@ -0,0 +1,51 @@
+-- Expense claims: a group member collects their expenses, with receipts, into a report and
+-- submits it; the group's owner or a manager approves or rejects it, and reimburses approved
+-- reports. Every status change is kept in expense_report_transitions.
+ALTER TABLE group_members DROP CONSTRAINT IF EXISTS group_members_role_check;
+ALTER TABLE group_members ADD CONSTRAINT group_members_role_check CHECK (role IN ('owner', 'manager', 'member'));
+
+CREATE TABLE IF NOT EXISTS expense_reports (
+    id         SERIAL PRIMARY KEY,
+    group_id   INTEGER NOT NULL REFERENCES groups(id),
+    user_id    INTEGER NOT NULL REFERENCES users(id),
+    title      TEXT NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'submitted', 'approved', 'rejected', 'reimbursed')),
+    payment_id INTEGER REFERENCES payments(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_expense_reports_user_id ON expense_reports (user_id);
+CREATE INDEX IF NOT EXISTS idx_expense_reports_group_status ON expense_reports (group_id, status);
+
+-- An expense can be claimed in one report; amount is fixed when the report is submitted
+CREATE TABLE IF NOT EXISTS expense_report_items (
+    report_id  INTEGER NOT NULL REFERENCES expense_reports(id) ON DELETE CASCADE,
+    expense_id INTEGER NOT NULL UNIQUE REFERENCES expenses(id) ON DELETE CASCADE,
+    amount     DOUBLE PRECISION NOT NULL,
+    note       TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (report_id, expense_id)
+);
+
+CREATE TABLE IF NOT EXISTS expense_report_receipts (
+    id           SERIAL PRIMARY KEY,
+    report_id    INTEGER NOT NULL,
+    expense_id   INTEGER NOT NULL,
+    filename     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         INTEGER NOT NULL,
+    data         BYTEA NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (report_id, expense_id) REFERENCES expense_report_items (report_id, expense_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_expense_report_receipts_report_id ON expense_report_receipts (report_id);
+
+CREATE TABLE IF NOT EXISTS expense_report_transitions (
+    id          SERIAL PRIMARY KEY,
+    report_id   INTEGER NOT NULL REFERENCES expense_reports(id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status   TEXT NOT NULL,
+    actor_id    INTEGER NOT NULL REFERENCES users(id),
+    comment     TEXT NOT NULL DEFAULT '',
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_expense_report_transitions_report_id ON expense_report_transitions (report_id);
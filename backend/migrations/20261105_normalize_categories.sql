@@ -0,0 +1,31 @@
+-- Fold categories that differ from another of the user's categories only by a plural "s"/"es"
+-- ("Foods" next to "Food") into the singular, then rewrite category names on expenses,
+-- payments, rules, learned mappings and goals to the user's spelling ("food" becomes "Food").
+CREATE TEMP TABLE category_aliases AS
+SELECT DISTINCT ON (p.id) p.id AS alias_id, p.user_id, p.name AS alias, s.id AS canonical_id, s.name AS canonical
+FROM categories p
+JOIN categories s ON s.user_id = p.user_id AND s.id <> p.id
+    AND lower(p.name) IN (lower(s.name) || 's', lower(s.name) || 'es')
+ORDER BY p.id, length(s.name) DESC;
+
+-- A singular nested under its own plural would otherwise end up as its own parent
+UPDATE categories c SET parent_id = NULL FROM category_aliases a WHERE c.id = a.canonical_id AND c.parent_id = a.alias_id;
+UPDATE categories c SET parent_id = a.canonical_id FROM category_aliases a WHERE c.parent_id = a.alias_id;
+DELETE FROM categories c USING category_aliases a WHERE c.id = a.alias_id;
+DROP TABLE category_aliases;
+
+UPDATE expenses t SET category = c.name FROM categories c
+WHERE c.user_id = t.user_id AND t.category <> c.name
+    AND lower(t.category) IN (lower(c.name), lower(c.name) || 's', lower(c.name) || 'es');
+UPDATE payments t SET category = c.name FROM categories c
+WHERE c.user_id = t.user_id AND t.category <> c.name
+    AND lower(t.category) IN (lower(c.name), lower(c.name) || 's', lower(c.name) || 'es');
+UPDATE category_rules t SET category = c.name FROM categories c
+WHERE c.user_id = t.user_id AND t.category <> c.name
+    AND lower(t.category) IN (lower(c.name), lower(c.name) || 's', lower(c.name) || 'es');
+UPDATE merchant_mappings t SET category = c.name FROM categories c
+WHERE c.user_id = t.user_id AND t.category <> c.name
+    AND lower(t.category) IN (lower(c.name), lower(c.name) || 's', lower(c.name) || 'es');
+UPDATE savings_goals t SET category = c.name FROM categories c
+WHERE c.user_id = t.user_id AND t.category <> c.name
+    AND lower(t.category) IN (lower(c.name), lower(c.name) || 's', lower(c.name) || 'es');
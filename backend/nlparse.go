@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// parsedExpense is a draft expense read from a sentence like "spent 250 on lunch yesterday"
+type parsedExpense struct {
+	Amount      float64  `json:"amount"`
+	Currency    string   `json:"currency"`
+	Date        string   `json:"date"`
+	Description string   `json:"description"`
+	Payee       string   `json:"payee,omitempty"`
+	Warnings    []string `json:"warnings"`
+}
+
+var (
+	monthNames = map[string]time.Month{
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April, "may": time.May, "jun": time.June,
+		"jul": time.July, "aug": time.August, "sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+	weekdayNames = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+
+	isoDateRe     = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	numericDateRe = regexp.MustCompile(`\b(\d{1,2})[/-](\d{1,2})(?:[/-](\d{2,4}))?\b`) // day first, as written in India
+	dayMonthRe    = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\b`)
+	monthDayRe    = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+	daysAgoRe     = regexp.MustCompile(`(?i)\b(\d+|a|one|two|three)\s+(day|week)s?\s+ago\b`)
+	weekdayRe     = regexp.MustCompile(`(?i)\b(?:(last|this past|on)\s+)?(sun|mon|tue|wed|thu|fri|sat)(?:day|nesday|rsday|urday|sday)\b`)
+	// Short day names are ordinary words too ("sun screen"), so they count only after a preposition
+	shortWeekdayRe = regexp.MustCompile(`(?i)\b(last|this past|on)\s+(sun|mon|tue|tues|wed|thu|thur|thurs|fri|sat)\b`)
+	relativeDayRe  = regexp.MustCompile(`(?i)\b(day before yesterday|yesterday|today|tonight|this morning|this evening)\b`)
+	amountBeforeRe = regexp.MustCompile(`(?i)(₹|\brs\.?|\binr\b|\$|€|£)\s*(\d[\d,]*(?:\.\d+)?)(\s*k\b)?`)
+	amountAfterRe  = regexp.MustCompile(`(?i)\b(\d[\d,]*(?:\.\d+)?)(\s*k\b)?\s*(rupees?\b|rs\b\.?|inr\b|/-|dollars?\b|usd\b|euros?\b)`)
+	bareAmountRe   = regexp.MustCompile(`(?i)\b(\d[\d,]*(?:\.\d+)?)(\s*k\b)?`)
+	payeeRe        = regexp.MustCompile(`\b(?:to|at|from)\s+([A-Z][\w&'.-]*(?:\s+[A-Z][\w&'.-]*)*)`)
+)
+
+// leadingFillers are dropped from the start of the description left after removing the amount and date
+var leadingFillers = map[string]bool{
+	"i": true, "spent": true, "spend": true, "paid": true, "pay": true, "bought": true, "buy": true, "gave": true,
+	"ordered": true, "got": true, "on": true, "for": true, "at": true, "to": true, "of": true, "worth": true, "a": true, "an": true, "the": true,
+}
+
+// trailingFillers are dropped from the end of the description
+var trailingFillers = map[string]bool{"on": true, "for": true, "at": true, "to": true, "of": true, "and": true}
+
+// currencyCode maps a matched symbol or word to an ISO currency code
+func currencyCode(s string) string {
+	s = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	switch {
+	case s == "$" || strings.HasPrefix(s, "dollar") || s == "usd":
+		return "USD"
+	case s == "€" || strings.HasPrefix(s, "euro"):
+		return "EUR"
+	case s == "£":
+		return "GBP"
+	}
+	return "INR"
+}
+
+// parseAmount parses "1,250.50" with an optional "k" multiplier
+func parseAmount(num, k string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.ReplaceAll(num, ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	if strings.TrimSpace(k) != "" {
+		v *= 1000
+	}
+	return roundMoney(v), true
+}
+
+// pastDate builds a date in the given month and day, in the year that makes it not lie in the future
+func pastDate(today time.Time, month time.Month, day, year int) (time.Time, bool) {
+	if year == 0 {
+		year = today.Year()
+		if time.Date(year, month, day, 0, 0, 0, 0, time.UTC).After(today) {
+			year--
+		}
+	} else if year < 100 {
+		year += 2000
+	}
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	// time.Date normalizes 31 Feb into March; reject it instead
+	if d.Month() != month || d.Day() != day {
+		return d, false
+	}
+	return d, true
+}
+
+// parseDate finds a date in text, returning it and the matched span (nil when none was found)
+func parseDate(text string, today time.Time) (time.Time, []int) {
+	if m := relativeDayRe.FindStringSubmatchIndex(text); m != nil {
+		switch strings.ToLower(text[m[2]:m[3]]) {
+		case "day before yesterday":
+			return today.AddDate(0, 0, -2), m[:2]
+		case "yesterday":
+			return today.AddDate(0, 0, -1), m[:2]
+		}
+		return today, m[:2]
+	}
+	if m := daysAgoRe.FindStringSubmatchIndex(text); m != nil {
+		n := map[string]int{"a": 1, "one": 1, "two": 2, "three": 3}[strings.ToLower(text[m[2]:m[3]])]
+		if n == 0 {
+			n, _ = strconv.Atoi(text[m[2]:m[3]])
+		}
+		if strings.EqualFold(text[m[4]:m[5]], "week") {
+			n *= 7
+		}
+		return today.AddDate(0, 0, -n), m[:2]
+	}
+	if m := isoDateRe.FindStringSubmatchIndex(text); m != nil {
+		y, _ := strconv.Atoi(text[m[2]:m[3]])
+		mo, _ := strconv.Atoi(text[m[4]:m[5]])
+		d, _ := strconv.Atoi(text[m[6]:m[7]])
+		if mo >= 1 && mo <= 12 {
+			if t, ok := pastDate(today, time.Month(mo), d, y); ok {
+				return t, m[:2]
+			}
+		}
+	}
+	if m := dayMonthRe.FindStringSubmatchIndex(text); m != nil {
+		d, _ := strconv.Atoi(text[m[2]:m[3]])
+		if t, ok := pastDate(today, monthNames[strings.ToLower(text[m[4]:m[5]])], d, 0); ok {
+			return t, m[:2]
+		}
+	}
+	if m := monthDayRe.FindStringSubmatchIndex(text); m != nil {
+		d, _ := strconv.Atoi(text[m[4]:m[5]])
+		if t, ok := pastDate(today, monthNames[strings.ToLower(text[m[2]:m[3]])], d, 0); ok {
+			return t, m[:2]
+		}
+	}
+	if m := numericDateRe.FindStringSubmatchIndex(text); m != nil {
+		d, _ := strconv.Atoi(text[m[2]:m[3]])
+		mo, _ := strconv.Atoi(text[m[4]:m[5]])
+		y := 0
+		if m[6] >= 0 {
+			y, _ = strconv.Atoi(text[m[6]:m[7]])
+		}
+		if mo >= 1 && mo <= 12 {
+			if t, ok := pastDate(today, time.Month(mo), d, y); ok {
+				return t, m[:2]
+			}
+		}
+	}
+	m := weekdayRe.FindStringSubmatchIndex(text)
+	if m == nil {
+		m = shortWeekdayRe.FindStringSubmatchIndex(text)
+	}
+	if m != nil {
+		wd := weekdayNames[strings.ToLower(text[m[4]:m[4]+3])]
+		back := (int(today.Weekday()) - int(wd) + 7) % 7
+		// "last Friday" on a Friday means a week ago; a bare "Friday" means today
+		if back == 0 && m[2] >= 0 && !strings.EqualFold(text[m[2]:m[3]], "on") {
+			back = 7
+		}
+		return today.AddDate(0, 0, -back), m[:2]
+	}
+	return today, nil
+}
+
+// parseExpenseText reads an amount, date, payee and description from a short sentence.
+// It is deterministic and needs no network, so it works offline.
+func parseExpenseText(text string, now time.Time) (parsedExpense, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	out := parsedExpense{Currency: "INR", Warnings: []string{}}
+	rest := text
+
+	// Dates go first so their digits aren't mistaken for the amount
+	date, span := parseDate(rest, today)
+	if span != nil {
+		rest = rest[:span[0]] + " " + rest[span[1]:]
+	} else {
+		out.Warnings = append(out.Warnings, "No date found; using today")
+	}
+	out.Date = date.Format("2006-01-02")
+
+	var amountSpan []int
+	if m := amountBeforeRe.FindStringSubmatchIndex(rest); m != nil {
+		out.Amount, _ = parseAmount(rest[m[4]:m[5]], substr(rest, m[6], m[7]))
+		out.Currency = currencyCode(rest[m[2]:m[3]])
+		amountSpan = m[:2]
+	} else if m := amountAfterRe.FindStringSubmatchIndex(rest); m != nil {
+		out.Amount, _ = parseAmount(rest[m[2]:m[3]], substr(rest, m[4], m[5]))
+		out.Currency = currencyCode(rest[m[6]:m[7]])
+		amountSpan = m[:2]
+	} else if m := bareAmountRe.FindStringSubmatchIndex(rest); m != nil {
+		out.Amount, _ = parseAmount(rest[m[2]:m[3]], substr(rest, m[4], m[5]))
+		amountSpan = m[:2]
+	}
+	if amountSpan == nil || out.Amount <= 0 {
+		return out, errors.New("Could not find an amount")
+	}
+	rest = rest[:amountSpan[0]] + " " + rest[amountSpan[1]:]
+	if out.Currency != "INR" {
+		out.Warnings = append(out.Warnings, "Amount is in "+out.Currency+"; expenses are recorded as entered without conversion")
+	}
+
+	if m := payeeRe.FindStringSubmatch(rest); m != nil {
+		out.Payee = m[1]
+	}
+
+	words := strings.Fields(strings.Trim(rest, " .,!-"))
+	for len(words) > 0 && leadingFillers[strings.ToLower(strings.Trim(words[0], ".,"))] {
+		words = words[1:]
+	}
+	for len(words) > 0 && trailingFillers[strings.ToLower(strings.Trim(words[len(words)-1], ".,"))] {
+		words = words[:len(words)-1]
+	}
+	out.Description = strings.Trim(strings.Join(words, " "), " .,")
+	if out.Description == "" {
+		out.Description = out.Payee
+	}
+	if out.Description == "" {
+		out.Warnings = append(out.Warnings, "No description found")
+	}
+	return out, nil
+}
+
+// substr is s[i:j], or "" for an unmatched optional group (i < 0)
+func substr(s string, i, j int) string {
+	if i < 0 {
+		return ""
+	}
+	return s[i:j]
+}
+
+func registerParseRoutes(auth *gin.RouterGroup) {
+	// Turn a sentence into a draft expense, categorized by the usual pipeline. With
+	// "create": true the draft is saved, refusing likely duplicates unless ?force=true.
+	auth.POST("/expenses/parse", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Text   string `json:"text"`
+			Create bool   `json:"create"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "text required"})
+			return
+		}
+		if len(req.Text) > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "text too long"})
+			return
+		}
+		draft, err := parseExpenseText(req.Text, time.Now())
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "draft": draft})
+			return
+		}
+		exp, err := expenseInput{Date: draft.Date, Amount: draft.Amount, Description: draft.Description}.toExpense()
+		if err == nil {
+			err = categorizeExpense(c.Request.Context(), userID, &exp)
+		}
+		if err != nil {
+			respondError(c, err, "Failed to categorize expense")
+			return
+		}
+		if !req.Create {
+			c.JSON(http.StatusOK, gin.H{"draft": draft, "expense": exp})
+			return
+		}
+
+		ctx := context.Background()
+		if c.Query("force") != "true" {
+			dups, err := findDuplicateExpenses(ctx, db, userID, exp, 0)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates"})
+				return
+			}
+			if len(dups) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Possible duplicate expense", "duplicates": dups, "draft": draft})
+				return
+			}
+		}
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			return insertExpense(ctx, tx, userID, &exp)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to add expense")
+			return
+		}
+		checkAnomalies(ctx, userID, expenseSubject(exp))
+		c.JSON(http.StatusCreated, gin.H{"draft": draft, "expense": exp})
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpenseText(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2026, 10, 14, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		text        string
+		amount      float64
+		currency    string
+		date        string
+		description string
+		payee       string
+	}{
+		{"spent 250 on lunch yesterday", 250, "INR", "2026-10-13", "lunch", ""},
+		{"Paid ₹1,200 to Ramesh for plumbing", 1200, "INR", "2026-10-14", "Ramesh for plumbing", "Ramesh"},
+		{"groceries 2.5k on 3rd Oct", 2500, "INR", "2026-10-03", "groceries", ""},
+		{"dinner at Olive Bistro 1800 rupees last friday", 1800, "INR", "2026-10-09", "dinner at Olive Bistro", "Olive Bistro"},
+		{"taxi $40 on 12/09", 40, "USD", "2026-09-12", "taxi", ""},
+		{"cinema 600 2 days ago", 600, "INR", "2026-10-12", "cinema", ""},
+		{"rent 15000 on 2026-10-01", 15000, "INR", "2026-10-01", "rent", ""},
+		{"coffee 120 on wed", 120, "INR", "2026-10-14", "coffee", ""},
+		{"Dec 28 gift 999", 999, "INR", "2025-12-28", "gift", ""},
+		{"bought sun screen for 300", 300, "INR", "2026-10-14", "sun screen", ""},
+	}
+	for _, tt := range tests {
+		got, err := parseExpenseText(tt.text, now)
+		if err != nil {
+			t.Errorf("parseExpenseText(%q): %v", tt.text, err)
+			continue
+		}
+		if got.Amount != tt.amount || got.Currency != tt.currency || got.Date != tt.date || got.Description != tt.description || got.Payee != tt.payee {
+			t.Errorf("parseExpenseText(%q) = %+v, want amount %v %s, date %s, description %q, payee %q",
+				tt.text, got, tt.amount, tt.currency, tt.date, tt.description, tt.payee)
+		}
+	}
+}
+
+func TestParseExpenseTextErrors(t *testing.T) {
+	now := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	for _, text := range []string{"lunch yesterday", "paid 0 for nothing"} {
+		if _, err := parseExpenseText(text, now); err == nil {
+			t.Errorf("parseExpenseText(%q) succeeded, want an error", text)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification is an in-app message for a user, optionally pointing at the row it is about
+type Notification struct {
+	ID         int        `json:"id"`
+	Type       string     `json:"type"`
+	Message    string     `json:"message"`
+	EntityType string     `json:"entity_type,omitempty"`
+	EntityID   *int       `json:"entity_id"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// notify stores a notification for userID. entityID 0 means it isn't about a particular row.
+func notify(ctx context.Context, q querier, userID int, kind, message, entityType string, entityID int) error {
+	var id *int
+	if entityID != 0 {
+		id = &entityID
+	}
+	_, err := q.Exec(ctx,
+		"INSERT INTO notifications (user_id, type, message, entity_type, entity_id) VALUES ($1, $2, $3, $4, $5)",
+		userID, kind, message, entityType, id)
+	return err
+}
+
+func registerNotificationRoutes(auth *gin.RouterGroup) {
+	// Newest first; ?unread=true lists only unread notifications
+	auth.GET("/notifications", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		query := "SELECT id, type, message, entity_type, entity_id, read_at, created_at FROM notifications WHERE user_id=$1"
+		if c.Query("unread") == "true" {
+			query += " AND read_at IS NULL"
+		}
+		rows, err := db.Query(context.Background(), query+" ORDER BY created_at DESC LIMIT 100", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		notifications := make([]Notification, 0)
+		for rows.Next() {
+			var n Notification
+			if err := rows.Scan(&n.ID, &n.Type, &n.Message, &n.EntityType, &n.EntityID, &n.ReadAt, &n.CreatedAt); err == nil {
+				notifications = append(notifications, n)
+			}
+		}
+		c.JSON(http.StatusOK, notifications)
+	})
+
+	auth.POST("/notifications/:id/read", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(),
+			"UPDATE notifications SET read_at=COALESCE(read_at, now()) WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Marked as read"})
+	})
+
+	auth.POST("/notifications/read-all", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "UPDATE notifications SET read_at=now() WHERE user_id=$1 AND read_at IS NULL", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Marked as read", "updated": res.RowsAffected()})
+	})
+}
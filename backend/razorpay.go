@@ -1 +1,656 @@
 package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/razorpay/razorpay-go"
+)
+
+// Payment order statuses
+const (
+	orderCreated           = "created"
+	orderPaid              = "paid"
+	orderFailed            = "failed"
+	orderPartiallyRefunded = "partially_refunded"
+	orderRefunded          = "refunded"
+)
+
+// orderTransitions lists the statuses each order status may move to.
+// A failed order can still be paid, since checkout lets the user retry on the same order.
+// Nothing leads back to paid once a refund is recorded: only recordGatewayRefund restores
+// it, when every refund has failed.
+var orderTransitions = map[string][]string{
+	orderCreated:           {orderPaid, orderFailed},
+	orderFailed:            {orderPaid},
+	orderPaid:              {orderPartiallyRefunded, orderRefunded},
+	orderPartiallyRefunded: {orderRefunded},
+	orderRefunded:          {orderPartiallyRefunded},
+}
+
+// PaymentOrder is a gateway order created at checkout
+type PaymentOrder struct {
+	ID                int       `json:"id"`
+	OrderID           string    `json:"order_id"`
+	UserID            int       `json:"user_id"`
+	ExpenseID         *int      `json:"expense_id"`
+	SettlementID      *int      `json:"settlement_id,omitempty"` // set for orders paying a settle-up transfer
+	Amount            int64     `json:"amount"`                  // paise
+	Currency          string    `json:"currency"`
+	Receipt           string    `json:"receipt"`
+	Status            string    `json:"status"`
+	RazorpayPaymentID *string   `json:"razorpay_payment_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// OrderTransition records one status change of a payment order
+type OrderTransition struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+const paymentOrderColumns = "id, order_id, user_id, expense_id, settlement_id, amount, currency, receipt, status, razorpay_payment_id, created_at, updated_at"
+
+func scanPaymentOrder(row pgx.Row) (PaymentOrder, error) {
+	var o PaymentOrder
+	err := row.Scan(&o.ID, &o.OrderID, &o.UserID, &o.ExpenseID, &o.SettlementID, &o.Amount, &o.Currency, &o.Receipt, &o.Status, &o.RazorpayPaymentID, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+// lockPaymentOrder loads and row-locks a payment order by its gateway order ID
+func lockPaymentOrder(ctx context.Context, tx pgx.Tx, orderID string) (PaymentOrder, error) {
+	o, err := scanPaymentOrder(tx.QueryRow(ctx, "SELECT "+paymentOrderColumns+" FROM payment_orders WHERE order_id=$1 FOR UPDATE", orderID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return o, newAPIError(http.StatusNotFound, "Order not found")
+	}
+	return o, err
+}
+
+// transitionOrder moves an order to a new status and logs the change.
+// Moving to the current status is a no-op, so redelivered events are harmless.
+func transitionOrder(ctx context.Context, tx pgx.Tx, order *PaymentOrder, to string) error {
+	if order.Status == to {
+		return nil
+	}
+	allowed := false
+	for _, s := range orderTransitions[order.Status] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return newAPIError(http.StatusConflict, fmt.Sprintf("Order cannot move from %s to %s", order.Status, to))
+	}
+	return setOrderStatus(ctx, tx, order, to)
+}
+
+// setOrderStatus records an order's new status and logs the change, without checking
+// orderTransitions
+func setOrderStatus(ctx context.Context, tx pgx.Tx, order *PaymentOrder, to string) error {
+	if _, err := tx.Exec(ctx,
+		"UPDATE payment_orders SET status=$1, razorpay_payment_id=$2, updated_at=now() WHERE id=$3",
+		to, order.RazorpayPaymentID, order.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO payment_order_transitions (payment_order_id, from_status, to_status) VALUES ($1, $2, $3)",
+		order.ID, order.Status, to); err != nil {
+		return err
+	}
+	order.Status = to
+	return nil
+}
+
+// razorpayPaymentEntity is the subset of a Razorpay payment object we use
+type razorpayPaymentEntity struct {
+	ID        string `json:"id"`
+	OrderID   string `json:"order_id"`
+	Amount    int64  `json:"amount"` // paise
+	Currency  string `json:"currency"`
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// razorpayRefundEntity is the subset of a Razorpay refund object we use
+type razorpayRefundEntity struct {
+	ID        string `json:"id"`
+	PaymentID string `json:"payment_id"`
+	Amount    int64  `json:"amount"` // paise
+	Status    string `json:"status"`
+}
+
+// razorpayWebhookEvent is the envelope Razorpay posts to the webhook endpoint
+type razorpayWebhookEvent struct {
+	Event   string `json:"event"`
+	Payload struct {
+		Payment struct {
+			Entity razorpayPaymentEntity `json:"entity"`
+		} `json:"payment"`
+		Refund struct {
+			Entity razorpayRefundEntity `json:"entity"`
+		} `json:"refund"`
+	} `json:"payload"`
+}
+
+// razorpaySignature returns the hex HMAC-SHA256 of payload, as Razorpay computes it
+func razorpaySignature(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validRazorpaySignature compares signatures in constant time
+func validRazorpaySignature(payload []byte, signature, secret string) bool {
+	if signature == "" || secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(razorpaySignature(payload, secret)), []byte(signature))
+}
+
+// razorpayGateway is the PaymentGateway backed by the Razorpay API
+type razorpayGateway struct {
+	client        *razorpay.Client
+	keyID         string
+	keySecret     string
+	webhookSecret string
+}
+
+func newRazorpayGateway(keyID, keySecret, webhookSecret string) *razorpayGateway {
+	return &razorpayGateway{
+		client:        razorpay.NewClient(keyID, keySecret),
+		keyID:         keyID,
+		keySecret:     keySecret,
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (g *razorpayGateway) Name() string  { return "razorpay" }
+func (g *razorpayGateway) KeyID() string { return g.keyID }
+
+func (g *razorpayGateway) CreateOrder(amount int64, currency, receipt string, notes map[string]string) (GatewayOrder, error) {
+	n := make(map[string]interface{}, len(notes))
+	for k, v := range notes {
+		n[k] = v
+	}
+	data := map[string]interface{}{
+		"amount":          amount, // amount in paise
+		"currency":        currency,
+		"receipt":         receipt,
+		"payment_capture": 1,
+		"notes":           n,
+	}
+	order, err := g.client.Order.Create(data, nil)
+	if err != nil {
+		return GatewayOrder{}, err
+	}
+	o := GatewayOrder{Amount: amount, Currency: currency, Receipt: receipt}
+	o.ID, _ = order["id"].(string)
+	o.Status, _ = order["status"].(string)
+	if o.ID == "" {
+		return o, errors.New("razorpay returned an order without id")
+	}
+	return o, nil
+}
+
+func (g *razorpayGateway) VerifySignature(orderID, paymentID, signature string) bool {
+	return validRazorpaySignature([]byte(orderID+"|"+paymentID), signature, g.keySecret)
+}
+
+func (g *razorpayGateway) VerifyWebhookSignature(body []byte, signature string) bool {
+	return validRazorpaySignature(body, signature, g.webhookSecret)
+}
+
+func (g *razorpayGateway) FetchPayment(paymentID string) (GatewayPayment, error) {
+	payment, err := g.client.Payment.Fetch(paymentID, nil, nil)
+	if err != nil {
+		return GatewayPayment{}, err
+	}
+	return razorpayPaymentFromMap(payment), nil
+}
+
+func (g *razorpayGateway) Refund(paymentID string, amount int64) (GatewayRefund, error) {
+	if amount == 0 {
+		// The API needs an explicit amount, so a full refund is whatever has not been refunded yet
+		payment, err := g.client.Payment.Fetch(paymentID, nil, nil)
+		if err != nil {
+			return GatewayRefund{}, err
+		}
+		total, _ := payment["amount"].(float64)
+		refunded, _ := payment["amount_refunded"].(float64)
+		amount = int64(total - refunded)
+	}
+	refund, err := g.client.Payment.Refund(paymentID, int(amount), nil, nil)
+	if err != nil {
+		return GatewayRefund{}, err
+	}
+	r := GatewayRefund{PaymentID: paymentID, Amount: amount}
+	r.ID, _ = refund["id"].(string)
+	r.Status, _ = refund["status"].(string)
+	return r, nil
+}
+
+// razorpayPaymentFromMap converts a payment object from the Razorpay SDK
+func razorpayPaymentFromMap(m map[string]interface{}) GatewayPayment {
+	var p GatewayPayment
+	p.ID, _ = m["id"].(string)
+	p.OrderID, _ = m["order_id"].(string)
+	p.Currency, _ = m["currency"].(string)
+	p.Status, _ = m["status"].(string)
+	if a, ok := m["amount"].(float64); ok {
+		p.Amount = int64(a)
+	}
+	if t, ok := m["created_at"].(float64); ok {
+		p.CreatedAt = time.Unix(int64(t), 0)
+	}
+	return p
+}
+
+// capturePaymentOrder marks an order paid and records its payment. Safe to call from both
+// /verify and the webhook, in either order and more than once. Orders already paid or
+// refunded are left alone, so a late capture event can't hide a refund.
+func capturePaymentOrder(ctx context.Context, tx pgx.Tx, orderID, gatewayPaymentID string, amount int64, paidAt time.Time) (Payment, bool, error) {
+	order, err := lockPaymentOrder(ctx, tx, orderID)
+	if err != nil {
+		return Payment{}, false, err
+	}
+	if order.Status != orderCreated && order.Status != orderFailed {
+		return Payment{}, false, nil
+	}
+	if amount == 0 {
+		amount = order.Amount
+	}
+	order.RazorpayPaymentID = &gatewayPaymentID
+	if err := transitionOrder(ctx, tx, &order, orderPaid); err != nil {
+		return Payment{}, false, err
+	}
+	pay, created, err := recordGatewayPayment(ctx, tx, order.UserID, order.ExpenseID, gatewayPaymentID, float64(amount)/100, paidAt)
+	if err != nil || !created || order.SettlementID == nil {
+		return pay, created, err
+	}
+	return pay, created, completeSettlement(ctx, tx, *order.SettlementID, &pay)
+}
+
+// createPaymentOrder creates a gateway order for userID and records it, optionally for an
+// expense or a settle-up transfer, whose IDs travel as notes so the order can be traced from
+// the gateway dashboard. The row is saved under a placeholder order ID before calling the
+// gateway, so an order the gateway created is never left without a record; q must not be a
+// transaction that could roll the placeholder back.
+func createPaymentOrder(ctx context.Context, q querier, userID int, amount int64, currency string, expenseID, settlementID *int) (GatewayOrder, error) {
+	if gateway == nil {
+		return GatewayOrder{}, newAPIError(http.StatusServiceUnavailable, "Payment gateway is not configured")
+	}
+	notes := map[string]string{"user_id": strconv.Itoa(userID)}
+	if expenseID != nil {
+		notes["expense_id"] = strconv.Itoa(*expenseID)
+	}
+	if settlementID != nil {
+		notes["settlement_id"] = strconv.Itoa(*settlementID)
+	}
+	receipt := "user-" + strconv.Itoa(userID) + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	var id int
+	err := q.QueryRow(ctx,
+		"INSERT INTO payment_orders (order_id, user_id, expense_id, settlement_id, amount, currency, receipt, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		"pending:"+receipt, userID, expenseID, settlementID, amount, currency, receipt, orderCreated).Scan(&id)
+	if err != nil {
+		return GatewayOrder{}, err
+	}
+	order, err := gateway.CreateOrder(amount, currency, receipt, notes)
+	if err != nil {
+		fmt.Println("[GATEWAY ERROR] Failed to create order:", err)
+		if _, delErr := q.Exec(ctx, "DELETE FROM payment_orders WHERE id=$1", id); delErr != nil {
+			fmt.Println("[GATEWAY ERROR] Failed to remove placeholder order", id, ":", delErr)
+		}
+		return order, newAPIError(http.StatusBadGateway, "Failed to create Razorpay order")
+	}
+	if _, err := q.Exec(ctx, "UPDATE payment_orders SET order_id=$1, updated_at=now() WHERE id=$2", order.ID, id); err != nil {
+		// The placeholder keeps the receipt the gateway order carries, so it can still be matched
+		fmt.Println("[GATEWAY ERROR] Failed to record order", order.ID, "for receipt", receipt, ":", err)
+		return order, err
+	}
+	return order, nil
+}
+
+// checkoutDetails is what the frontend needs to open checkout for an order
+func checkoutDetails(order GatewayOrder) gin.H {
+	// Checkout needs the public key ID; the frontend no longer hard-codes it
+	return gin.H{
+		"id":       order.ID,
+		"amount":   order.Amount,
+		"currency": order.Currency,
+		"receipt":  order.Receipt,
+		"status":   order.Status,
+		"key_id":   gateway.KeyID(),
+		"gateway":  gateway.Name(),
+	}
+}
+
+// recordGatewayPayment stores a captured gateway payment as a Payment row, linking it to the
+// expense when one is given. It is idempotent on the gateway payment ID: created is false when
+// the payment was already recorded (e.g. by /verify before the webhook arrived).
+func recordGatewayPayment(ctx context.Context, tx pgx.Tx, userID int, expenseID *int, gatewayPaymentID string, amount float64, paidAt time.Time) (pay Payment, created bool, err error) {
+	if expenseID != nil {
+		_, err := lockOwnedExpense(ctx, tx, *expenseID, userID)
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			// The expense was deleted after checkout; keep the money on record unlinked
+			expenseID = nil
+		} else if err != nil {
+			return pay, false, err
+		}
+	}
+	pay.UserID = userID
+	pay.PaymentDate = paidAt
+	pay.Amount = amount
+	pay.ExpenseID = expenseID
+	var description *string
+	if expenseID == nil {
+		d := "Razorpay payment " + gatewayPaymentID
+		description = &d
+		pay.Description = description
+	}
+	err = tx.QueryRow(ctx,
+		"INSERT INTO payments (user_id, payment_date, amount, expense_id, description, razorpay_payment_id) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (razorpay_payment_id) DO NOTHING RETURNING id",
+		userID, paidAt, amount, expenseID, description, gatewayPaymentID).Scan(&pay.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pay, false, nil
+	}
+	if err != nil {
+		return pay, false, err
+	}
+	if expenseID != nil {
+		exp, err := reconcileExpense(ctx, tx, *expenseID, userID)
+		if err != nil {
+			return pay, false, err
+		}
+		pay.Category = &exp.Category
+		pay.Description = &exp.Description
+	}
+	return pay, true, nil
+}
+
+// webhookOrderKnown reports whether a webhook event's order was created here. Events for other
+// orders (another integration on the same account, or a placeholder that was never recorded)
+// are logged and acknowledged, since failing them would only make Razorpay retry forever.
+func webhookOrderKnown(ctx context.Context, q querier, eventID, orderID string) (bool, error) {
+	var known bool
+	if err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM payment_orders WHERE order_id=$1)", orderID).Scan(&known); err != nil {
+		return false, err
+	}
+	if !known {
+		fmt.Println("[RAZORPAY WEBHOOK] Ignoring event", eventID, "for unknown order", orderID)
+	}
+	return known, nil
+}
+
+func registerRazorpayRoutes(r *gin.Engine, auth *gin.RouterGroup) {
+	// Razorpay order creation endpoint
+	auth.POST("/razorpay/order", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Amount    int64  `json:"amount"`
+			Currency  string `json:"currency"`
+			ExpenseID *int   `json:"expense_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if req.Amount <= 0 || req.Currency == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount and currency required"})
+			return
+		}
+		if req.ExpenseID != nil {
+			var exists bool
+			err := db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM expenses WHERE id=$1 AND user_id=$2)", *req.ExpenseID, userID).Scan(&exists)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+				return
+			}
+		}
+		order, err := createPaymentOrder(context.Background(), db, userID, req.Amount, req.Currency, req.ExpenseID, nil)
+		if err != nil {
+			respondError(c, err, "Failed to save order")
+			return
+		}
+		c.JSON(http.StatusOK, checkoutDetails(order))
+	})
+
+	// Simulate checkout against the fake gateway: returns what Razorpay checkout would give the
+	// browser, ready to post to /razorpay/verify. Only registered when PAYMENT_GATEWAY=fake.
+	if fake, ok := gateway.(*fakeGateway); ok {
+		auth.POST("/razorpay/fake/capture", func(c *gin.Context) {
+			userID := getUserIDFromToken(c)
+			var req struct {
+				OrderID string `json:"order_id"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil || req.OrderID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "order_id required"})
+				return
+			}
+			var ownerID int
+			err := db.QueryRow(context.Background(), "SELECT user_id FROM payment_orders WHERE order_id=$1", req.OrderID).Scan(&ownerID)
+			if err != nil || ownerID != userID {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			payment, signature, err := fake.Capture(req.OrderID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"razorpay_order_id":   req.OrderID,
+				"razorpay_payment_id": payment.ID,
+				"razorpay_signature":  signature,
+			})
+		})
+	}
+
+	// List the caller's payment orders, newest first. ?status= filters by status.
+	auth.GET("/razorpay/orders", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		query := "SELECT " + paymentOrderColumns + " FROM payment_orders WHERE user_id=$1"
+		args := []interface{}{userID}
+		if status := c.Query("status"); status != "" {
+			query += " AND status=$2"
+			args = append(args, status)
+		}
+		rows, err := db.Query(context.Background(), query+" ORDER BY created_at DESC", args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		orders := make([]PaymentOrder, 0)
+		for rows.Next() {
+			if o, err := scanPaymentOrder(rows); err == nil {
+				orders = append(orders, o)
+			}
+		}
+		c.JSON(http.StatusOK, orders)
+	})
+
+	// One payment order with its status history
+	auth.GET("/razorpay/orders/:order_id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		order, err := scanPaymentOrder(db.QueryRow(context.Background(),
+			"SELECT "+paymentOrderColumns+" FROM payment_orders WHERE order_id=$1 AND user_id=$2", c.Param("order_id"), userID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		rows, err := db.Query(context.Background(),
+			"SELECT from_status, to_status, changed_at FROM payment_order_transitions WHERE payment_order_id=$1 ORDER BY changed_at", order.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		transitions := make([]OrderTransition, 0)
+		for rows.Next() {
+			var t OrderTransition
+			if err := rows.Scan(&t.FromStatus, &t.ToStatus, &t.ChangedAt); err == nil {
+				transitions = append(transitions, t)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"order": order, "transitions": transitions})
+	})
+
+	// Verify a checkout response and record the payment without waiting for the webhook
+	auth.POST("/razorpay/verify", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			OrderID   string `json:"razorpay_order_id"`
+			PaymentID string `json:"razorpay_payment_id"`
+			Signature string `json:"razorpay_signature"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.OrderID == "" || req.PaymentID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "razorpay_order_id, razorpay_payment_id and razorpay_signature required"})
+			return
+		}
+		if gateway == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payment gateway is not configured"})
+			return
+		}
+		if !gateway.VerifySignature(req.OrderID, req.PaymentID, req.Signature) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment signature"})
+			return
+		}
+		// Record what the gateway actually captured rather than trusting the order amount
+		gp, err := gateway.FetchPayment(req.PaymentID)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch payment"})
+			return
+		}
+		if gp.OrderID != req.OrderID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment does not belong to order"})
+			return
+		}
+		if gp.Status != "captured" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment not captured yet", "status": gp.Status})
+			return
+		}
+		var pay Payment
+		var created bool
+		ctx := context.Background()
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			var ownerID int
+			if err := tx.QueryRow(ctx, "SELECT user_id FROM payment_orders WHERE order_id=$1", req.OrderID).Scan(&ownerID); err != nil || ownerID != userID {
+				return newAPIError(http.StatusNotFound, "Order not found")
+			}
+			var err error
+			pay, created, err = capturePaymentOrder(ctx, tx, req.OrderID, req.PaymentID, gp.Amount, gp.CreatedAt)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to record payment")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"verified": true, "recorded": created, "payment": pay})
+	})
+
+	// Razorpay webhook. Authenticated by signature rather than JWT, so it lives outside /api.
+	r.POST("/webhooks/razorpay", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid body"})
+			return
+		}
+		if gateway == nil || !gateway.VerifyWebhookSignature(body, c.GetHeader("X-Razorpay-Signature")) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+			return
+		}
+		var event razorpayWebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event"})
+			return
+		}
+		entity := event.Payload.Payment.Entity
+		refund := event.Payload.Refund.Entity
+		eventID := c.GetHeader("X-Razorpay-Event-Id")
+		if eventID == "" {
+			eventID = event.Event + ":" + entity.ID + ":" + refund.ID
+		}
+
+		ctx := context.Background()
+		var duplicate bool
+		err = withTx(ctx, func(tx pgx.Tx) error {
+			// Recording the event in the same transaction as its effects makes redelivery a no-op,
+			// while a failed attempt leaves no record so Razorpay's retry is processed again
+			res, err := tx.Exec(ctx,
+				"INSERT INTO razorpay_webhook_events (event_id, event_type) VALUES ($1, $2) ON CONFLICT (event_id) DO NOTHING",
+				eventID, event.Event)
+			if err != nil {
+				return err
+			}
+			if res.RowsAffected() == 0 {
+				duplicate = true
+				return nil
+			}
+			switch event.Event {
+			case "payment.captured":
+				if entity.ID == "" || entity.OrderID == "" {
+					return newAPIError(http.StatusBadRequest, "Payment entity missing id or order_id")
+				}
+				paidAt := time.Now()
+				if entity.CreatedAt > 0 {
+					paidAt = time.Unix(entity.CreatedAt, 0)
+				}
+				if known, err := webhookOrderKnown(ctx, tx, eventID, entity.OrderID); err != nil || !known {
+					return err
+				}
+				_, _, err = capturePaymentOrder(ctx, tx, entity.OrderID, entity.ID, entity.Amount, paidAt)
+				return err
+			case "payment.failed":
+				if known, err := webhookOrderKnown(ctx, tx, eventID, entity.OrderID); err != nil || !known {
+					return err
+				}
+				order, err := lockPaymentOrder(ctx, tx, entity.OrderID)
+				if err != nil {
+					return err
+				}
+				// A late failure for an attempt on an order that has since been paid changes nothing
+				if order.Status != orderCreated {
+					return nil
+				}
+				return transitionOrder(ctx, tx, &order, orderFailed)
+			case "refund.processed", "refund.failed":
+				if refund.ID == "" || refund.PaymentID == "" {
+					return newAPIError(http.StatusBadRequest, "Refund entity missing id or payment_id")
+				}
+				status := refundProcessed
+				if event.Event == "refund.failed" {
+					status = refundFailed
+				}
+				_, err := recordGatewayRefund(ctx, tx, GatewayRefund{ID: refund.ID, PaymentID: refund.PaymentID, Amount: refund.Amount, Status: status}, "")
+				return err
+			}
+			// Other events are acknowledged so Razorpay stops retrying them
+			return nil
+		})
+		if err != nil {
+			fmt.Println("[RAZORPAY WEBHOOK] Failed to process event", eventID+":", err)
+			respondError(c, err, "Failed to process event")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "duplicate": duplicate})
+	})
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestValidRazorpaySignature(t *testing.T) {
+	payload := []byte("order_1|pay_1")
+	sig := razorpaySignature(payload, "secret")
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		secret    string
+		want      bool
+	}{
+		{"valid", payload, sig, "secret", true},
+		{"wrong secret", payload, sig, "other", false},
+		{"tampered payload", []byte("order_1|pay_2"), sig, "secret", false},
+		{"missing signature", payload, "", "secret", false},
+		{"missing secret", payload, sig, "", false},
+	}
+	for _, tt := range tests {
+		if got := validRazorpaySignature(tt.payload, tt.signature, tt.secret); got != tt.want {
+			t.Errorf("%s: validRazorpaySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFakeGatewayCapture(t *testing.T) {
+	g := newFakeGateway()
+	order, err := g.CreateOrder(25000, "INR", "receipt-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, signature, err := g.Capture(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.VerifySignature(order.ID, payment.ID, signature) {
+		t.Error("checkout signature does not verify")
+	}
+	if g.VerifySignature(order.ID, "pay_other", signature) {
+		t.Error("signature verified for another payment")
+	}
+	fetched, err := g.FetchPayment(payment.ID)
+	if err != nil || fetched.Status != "captured" || fetched.Amount != 25000 || fetched.OrderID != order.ID {
+		t.Errorf("FetchPayment = %+v, %v", fetched, err)
+	}
+	if _, _, err := g.Capture("order_missing"); err == nil {
+		t.Error("captured an unknown order")
+	}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{orderCreated, orderPaid, true},
+		{orderCreated, orderFailed, true},
+		{orderFailed, orderPaid, true},
+		{orderPaid, orderFailed, false},
+		{orderPaid, orderCreated, false},
+		{orderPaid, orderPartiallyRefunded, true},
+		{orderPartiallyRefunded, orderRefunded, true},
+		{orderPartiallyRefunded, orderPaid, false},
+		{orderRefunded, orderPartiallyRefunded, true},
+		{orderRefunded, orderPaid, false},
+		{orderCreated, orderRefunded, false},
+	}
+	for _, tt := range tests {
+		got := false
+		for _, s := range orderTransitions[tt.from] {
+			got = got || s == tt.to
+		}
+		if got != tt.want {
+			t.Errorf("%s -> %s allowed = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// TestGatewayPaymentFlow runs checkout end to end against the fake gateway: an order is
+// created, captured, verified by the browser and then confirmed by (repeated) webhooks, and
+// exactly one payment is recorded. It needs TEST_DATABASE_URL to point at a database with the
+// schema and migrations applied, and is skipped otherwise.
+func TestGatewayPaymentFlow(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	prevDB, prevGateway := db, gateway
+	fake := newFakeGateway()
+	db, gateway = pool, fake
+	defer func() { db, gateway = prevDB, prevGateway }()
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	var userID, expenseID int
+	if err := db.QueryRow(ctx,
+		"INSERT INTO users (name, email, password_hash, verified) VALUES ($1, $2, 'x', true) RETURNING id",
+		"gateway-test-"+suffix, "gateway-test-"+suffix+"@example.com").Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	var eventIDs []string
+	t.Cleanup(func() {
+		db.Exec(ctx, "DELETE FROM razorpay_webhook_events WHERE event_id = ANY($1)", eventIDs)
+		db.Exec(ctx, "DELETE FROM payment_orders WHERE user_id=$1", userID)
+		db.Exec(ctx, "DELETE FROM refunds WHERE user_id=$1", userID)
+		db.Exec(ctx, "DELETE FROM payments WHERE user_id=$1", userID)
+		db.Exec(ctx, "DELETE FROM expenses WHERE user_id=$1", userID)
+		db.Exec(ctx, "DELETE FROM users WHERE id=$1", userID)
+	})
+	if err := db.QueryRow(ctx,
+		"INSERT INTO expenses (user_id, date, category, amount, payment_status, description, paid) VALUES ($1, $2, '', 250, $3, 'Gateway test', false) RETURNING id",
+		userID, time.Now(), statusUnpaid).Scan(&expenseID); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRazorpayRoutes(r, r.Group("/api", authMiddleware()))
+	token, err := generateJWT(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(path string, raw []byte, header map[string]string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+	do := func(path string, body any) (int, map[string]any) {
+		raw, _ := json.Marshal(body)
+		return post(path, raw, nil)
+	}
+	orderStatus := func(orderID string) string {
+		var status string
+		if err := db.QueryRow(ctx, "SELECT status FROM payment_orders WHERE order_id=$1", orderID).Scan(&status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+	paymentCount := func(paymentID string) int {
+		var n int
+		if err := db.QueryRow(ctx, "SELECT count(*) FROM payments WHERE razorpay_payment_id=$1", paymentID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	webhook := func(eventID string, event any) (int, map[string]any) {
+		raw, _ := json.Marshal(event)
+		eventIDs = append(eventIDs, eventID)
+		return post("/webhooks/razorpay", raw, map[string]string{
+			"X-Razorpay-Event-Id":  eventID,
+			"X-Razorpay-Signature": razorpaySignature(raw, fakeGatewaySecret),
+		})
+	}
+	capturedEvent := func(orderID, paymentID string) gin.H {
+		return gin.H{"event": "payment.captured", "payload": gin.H{"payment": gin.H{"entity": gin.H{
+			"id": paymentID, "order_id": orderID, "amount": 25000, "currency": "INR", "status": "captured", "created_at": time.Now().Unix(),
+		}}}}
+	}
+
+	code, body := do("/api/razorpay/order", gin.H{"amount": 25000, "currency": "INR", "expense_id": expenseID})
+	if code != http.StatusOK {
+		t.Fatalf("create order: %d %v", code, body)
+	}
+	orderID, _ := body["id"].(string)
+	if got := orderStatus(orderID); got != orderCreated {
+		t.Fatalf("new order status = %q, want %q", got, orderCreated)
+	}
+
+	payment, signature, err := fake.Capture(orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, body = do("/api/razorpay/verify", gin.H{"razorpay_order_id": orderID, "razorpay_payment_id": payment.ID, "razorpay_signature": signature})
+	if code != http.StatusOK || body["recorded"] != true {
+		t.Fatalf("verify: %d %v", code, body)
+	}
+	if got := orderStatus(orderID); got != orderPaid {
+		t.Fatalf("order status after verify = %q, want %q", got, orderPaid)
+	}
+
+	// The webhook arrives after /verify recorded the payment, then is redelivered
+	for i, eventID := range []string{"evt_" + suffix, "evt_" + suffix, "evt_" + suffix + "_b"} {
+		code, body = webhook(eventID, capturedEvent(orderID, payment.ID))
+		if code != http.StatusOK {
+			t.Fatalf("webhook %d: %d %v", i, code, body)
+		}
+		if wantDup := i == 1; body["duplicate"] != wantDup {
+			t.Errorf("webhook %d duplicate = %v, want %v", i, body["duplicate"], wantDup)
+		}
+		if n := paymentCount(payment.ID); n != 1 {
+			t.Fatalf("after webhook %d there are %d payments, want 1", i, n)
+		}
+	}
+
+	var transitions []string
+	rows, err := db.Query(ctx,
+		"SELECT t.from_status || '->' || t.to_status FROM payment_order_transitions t JOIN payment_orders o ON o.id=t.payment_order_id WHERE o.order_id=$1 ORDER BY t.id", orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var s string
+		rows.Scan(&s)
+		transitions = append(transitions, s)
+	}
+	rows.Close()
+	if want := []string{orderCreated + "->" + orderPaid}; fmt.Sprint(transitions) != fmt.Sprint(want) {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+
+	var status string
+	if err := db.QueryRow(ctx, "SELECT payment_status FROM expenses WHERE id=$1", expenseID).Scan(&status); err != nil || status != statusPaid {
+		t.Errorf("expense payment_status = %q (%v), want %q", status, err, statusPaid)
+	}
+
+	// A capture event redelivered after a full refund leaves the order refunded
+	if err := withTx(ctx, func(tx pgx.Tx) error {
+		_, err := recordGatewayRefund(ctx, tx, GatewayRefund{ID: "rfnd_" + suffix, PaymentID: payment.ID, Amount: 25000, Status: refundProcessed}, "test")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if code, body = webhook("evt_"+suffix+"_late", capturedEvent(orderID, payment.ID)); code != http.StatusOK {
+		t.Fatalf("late webhook: %d %v", code, body)
+	}
+	if got := orderStatus(orderID); got != orderRefunded {
+		t.Errorf("order status after a late capture = %q, want %q", got, orderRefunded)
+	}
+
+	// Events for orders created elsewhere are acknowledged so the gateway stops retrying
+	code, body = webhook("evt_"+suffix+"_unknown", capturedEvent("order_unknown_"+suffix, "pay_unknown_"+suffix))
+	if code != http.StatusOK {
+		t.Errorf("webhook for unknown order: %d %v", code, body)
+	}
+}
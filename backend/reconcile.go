@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Expense payment statuses, derived from the sum of linked payments
+const (
+	statusUnpaid        = "Unpaid"
+	statusPartiallyPaid = "Partially Paid"
+	statusPaid          = "Paid"
+	statusOverpaid      = "Overpaid"
+)
+
+// roundMoney rounds an amount to two decimal places
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// paymentStatusFor derives an expense's payment status from its amount and the total paid against it
+func paymentStatusFor(amount, paid float64) string {
+	amount, paid = roundMoney(amount), roundMoney(paid)
+	switch {
+	case paid <= 0:
+		return statusUnpaid
+	case paid < amount:
+		return statusPartiallyPaid
+	case paid == amount:
+		return statusPaid
+	default:
+		return statusOverpaid
+	}
+}
+
+// statusNeedsReconcile reports whether an edit from prev to exp changes the payment status:
+// payments decide the status of an expense that has any, while a status set by hand when the
+// expense was created stands until the amount changes
+func statusNeedsReconcile(prev, exp Expense) bool {
+	return exp.Amount != prev.Amount || exp.PaidAmount > 0
+}
+
+// lockOwnedExpense loads and row-locks an expense, failing with 404 unless it belongs to userID
+func lockOwnedExpense(ctx context.Context, tx pgx.Tx, expenseID, userID int) (Expense, error) {
+	exp, err := scanExpense(tx.QueryRow(ctx, "SELECT "+expenseColumns+" FROM expenses WHERE id=$1 AND user_id=$2 FOR UPDATE", expenseID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return exp, newAPIError(http.StatusNotFound, "Expense not found")
+	}
+	return exp, err
+}
+
+// reconcileExpense recomputes payment_status and paid for a user's expense from its linked payments.
+// It must run in the same transaction as the payment change so the two never disagree.
+func reconcileExpense(ctx context.Context, tx pgx.Tx, expenseID, userID int) (Expense, error) {
+	exp, err := lockOwnedExpense(ctx, tx, expenseID, userID)
+	if err != nil {
+		return exp, err
+	}
+	exp.PaymentStatus = paymentStatusFor(exp.Amount, exp.PaidAmount)
+	exp.Paid = exp.PaymentStatus == statusPaid || exp.PaymentStatus == statusOverpaid
+	_, err = tx.Exec(ctx, "UPDATE expenses SET payment_status=$1, paid=$2 WHERE id=$3 AND user_id=$4", exp.PaymentStatus, exp.Paid, expenseID, userID)
+	return exp, err
+}
+
+func registerReconcileRoutes(auth *gin.RouterGroup) {
+	// Outstanding balance of an expense together with the payments made against it
+	auth.GET("/expenses/:id/balance", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		id := atoi(c.Param("id"))
+		exp, err := scanExpense(db.QueryRow(context.Background(),
+			"SELECT "+expenseColumns+" FROM expenses WHERE id=$1 AND user_id=$2", id, userID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+		rows, err := db.Query(context.Background(),
+			"SELECT id, user_id, payment_date, amount, expense_id, category, description FROM payments WHERE expense_id=$1 AND user_id=$2 ORDER BY payment_date", id, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		payments := make([]Payment, 0)
+		for rows.Next() {
+			var pay Payment
+			if err := rows.Scan(&pay.ID, &pay.UserID, &pay.PaymentDate, &pay.Amount, &pay.ExpenseID, &pay.Category, &pay.Description); err == nil {
+				payments = append(payments, pay)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"expense_id":     exp.ID,
+			"amount":         exp.Amount,
+			"paid_amount":    exp.PaidAmount,
+			"outstanding":    exp.Outstanding(),
+			"payment_status": exp.PaymentStatus,
+			"payments":       payments,
+		})
+	})
+}
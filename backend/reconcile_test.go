@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestPaymentStatusFor(t *testing.T) {
+	tests := []struct {
+		amount, paid float64
+		want         string
+	}{
+		{100, 0, statusUnpaid},
+		{100, -5, statusUnpaid},
+		{100, 40, statusPartiallyPaid},
+		{100, 99.99, statusPartiallyPaid},
+		{100, 100, statusPaid},
+		// Float sums of partial payments still count as paid in full
+		{0.3, 0.1 + 0.2, statusPaid},
+		{100, 100.004, statusPaid},
+		{100, 100.01, statusOverpaid},
+		{0, 10, statusOverpaid},
+	}
+	for _, tt := range tests {
+		if got := paymentStatusFor(tt.amount, tt.paid); got != tt.want {
+			t.Errorf("paymentStatusFor(%v, %v) = %q, want %q", tt.amount, tt.paid, got, tt.want)
+		}
+	}
+}
+
+func TestStatusNeedsReconcile(t *testing.T) {
+	// Marked paid by hand when it was created, with no payment records
+	manual := Expense{Amount: 500, Description: "Rent", Paid: true, PaymentStatus: statusPaid}
+	withPayments := Expense{Amount: 500, Description: "Rent", PaidAmount: 200, PaymentStatus: statusPartiallyPaid}
+	tests := []struct {
+		name      string
+		prev, exp Expense
+		want      bool
+	}{
+		{"description only", manual, Expense{Amount: 500, Description: "House rent", Paid: true, PaymentStatus: statusPaid}, false},
+		{"amount changed", manual, Expense{Amount: 600, Description: "Rent", Paid: true, PaymentStatus: statusPaid}, true},
+		{"has payments", withPayments, Expense{Amount: 500, Description: "House rent", PaidAmount: 200, PaymentStatus: statusPartiallyPaid}, true},
+	}
+	for _, tt := range tests {
+		if got := statusNeedsReconcile(tt.prev, tt.exp); got != tt.want {
+			t.Errorf("%s: statusNeedsReconcile = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Refund statuses, as reported by the gateway
+const (
+	refundPending   = "pending"
+	refundProcessed = "processed"
+	refundFailed    = "failed"
+)
+
+// Refund is money returned to the payer for a gateway payment
+type Refund struct {
+	ID              int       `json:"id"`
+	PaymentID       int       `json:"payment_id"`
+	UserID          int       `json:"user_id"`
+	GatewayRefundID string    `json:"gateway_refund_id"`
+	Amount          float64   `json:"amount"`
+	Status          string    `json:"status"`
+	Reason          string    `json:"reason"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+const refundColumns = "id, payment_id, user_id, gateway_refund_id, amount, status, reason, created_at"
+
+func scanRefund(row pgx.Row) (Refund, error) {
+	var r Refund
+	err := row.Scan(&r.ID, &r.PaymentID, &r.UserID, &r.GatewayRefundID, &r.Amount, &r.Status, &r.Reason, &r.CreatedAt)
+	return r, err
+}
+
+// refundedAmount is the total of a payment's refunds that have not failed
+func refundedAmount(ctx context.Context, q querier, paymentID int) (float64, error) {
+	var total float64
+	err := q.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id=$1 AND status<>$2", paymentID, refundFailed).Scan(&total)
+	return total, err
+}
+
+// recordGatewayRefund stores or updates a refund reported by the gateway, then brings the linked
+// expense or card statement and the payment order in line with it. It is idempotent on the gateway refund ID, so the
+// refund endpoint and the refund.* webhooks can both report the same refund in any order.
+func recordGatewayRefund(ctx context.Context, tx pgx.Tx, gr GatewayRefund, reason string) (Refund, error) {
+	var paymentID, userID int
+	var paymentAmount float64
+	var expenseID, statementID *int
+	err := tx.QueryRow(ctx,
+		"SELECT id, user_id, amount, expense_id, statement_id FROM payments WHERE razorpay_payment_id=$1 FOR UPDATE", gr.PaymentID).
+		Scan(&paymentID, &userID, &paymentAmount, &expenseID, &statementID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Refund{}, newAPIError(http.StatusNotFound, "Payment not found")
+	}
+	if err != nil {
+		return Refund{}, err
+	}
+	status := gr.Status
+	if status != refundProcessed && status != refundFailed {
+		status = refundPending
+	}
+	// A pending refund can settle either way; once settled, late or repeated reports don't change it
+	refund, err := scanRefund(tx.QueryRow(ctx,
+		"INSERT INTO refunds (payment_id, user_id, gateway_refund_id, amount, status, reason) VALUES ($1, $2, $3, $4, $5, $6) "+
+			"ON CONFLICT (gateway_refund_id) DO UPDATE SET status = CASE WHEN refunds.status=$7 THEN EXCLUDED.status ELSE refunds.status END "+
+			"RETURNING "+refundColumns,
+		paymentID, userID, gr.ID, float64(gr.Amount)/100, status, reason, refundPending))
+	if err != nil {
+		return refund, err
+	}
+
+	if expenseID != nil {
+		if _, err := reconcileExpense(ctx, tx, *expenseID, userID); err != nil {
+			return refund, err
+		}
+	}
+	if statementID != nil {
+		if _, err := reconcileStatement(ctx, tx, *statementID, userID); err != nil {
+			return refund, err
+		}
+	}
+
+	refunded, err := refundedAmount(ctx, tx, paymentID)
+	if err != nil {
+		return refund, err
+	}
+	order, err := scanPaymentOrder(tx.QueryRow(ctx,
+		"SELECT "+paymentOrderColumns+" FROM payment_orders WHERE razorpay_payment_id=$1 FOR UPDATE", gr.PaymentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return refund, nil
+	}
+	if err != nil {
+		return refund, err
+	}
+	// Recomputed on every event, so a refund that fails after counting as pending hands the
+	// money back to the order
+	switch order.Status {
+	case orderPaid, orderPartiallyRefunded, orderRefunded:
+		to := orderStatusForRefunds(paymentAmount, refunded)
+		if to == orderPaid && order.Status != orderPaid {
+			// orderTransitions has no way back to paid, so captures can't undo a refund
+			return refund, setOrderStatus(ctx, tx, &order, to)
+		}
+		err = transitionOrder(ctx, tx, &order, to)
+	}
+	return refund, err
+}
+
+// orderStatusForRefunds is the status of a paid order given how much of its payment has been
+// refunded, counting refunds that haven't failed
+func orderStatusForRefunds(paid, refunded float64) string {
+	paid, refunded = roundMoney(paid), roundMoney(refunded)
+	switch {
+	case refunded <= 0:
+		return orderPaid
+	case refunded < paid:
+		return orderPartiallyRefunded
+	default:
+		return orderRefunded
+	}
+}
+
+func registerRefundRoutes(auth *gin.RouterGroup) {
+	// Refund a gateway payment in full, or partially when amount (in rupees) is given
+	auth.POST("/payments/:id/refund", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Amount float64 `json:"amount"`
+			Reason string  `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if req.Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+			return
+		}
+		if gateway == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payment gateway is not configured"})
+			return
+		}
+		var refund Refund
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var paymentID int
+			var amount float64
+			var gatewayPaymentID *string
+			err := tx.QueryRow(ctx,
+				"SELECT id, amount, razorpay_payment_id FROM payments WHERE id=$1 AND user_id=$2 FOR UPDATE", atoi(c.Param("id")), userID).
+				Scan(&paymentID, &amount, &gatewayPaymentID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusNotFound, "Payment not found")
+			}
+			if err != nil {
+				return err
+			}
+			if gatewayPaymentID == nil {
+				return newAPIError(http.StatusBadRequest, "Only gateway payments can be refunded")
+			}
+			refunded, err := refundedAmount(ctx, tx, paymentID)
+			if err != nil {
+				return err
+			}
+			refundable := roundMoney(amount - refunded)
+			refundAmount := req.Amount
+			if refundAmount == 0 {
+				refundAmount = refundable
+			}
+			if refundable <= 0 {
+				return newAPIError(http.StatusConflict, "Payment is already fully refunded")
+			}
+			if roundMoney(refundAmount) > refundable {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Amount exceeds refundable balance of %.2f", refundable))
+			}
+			// The payment row stays locked while the gateway is called, so concurrent requests cannot
+			// refund the same money twice. If the commit fails after the gateway accepted the refund,
+			// the refund.processed webhook records it.
+			gr, err := gateway.Refund(*gatewayPaymentID, int64(math.Round(refundAmount*100)))
+			if err != nil {
+				fmt.Println("[GATEWAY ERROR] Refund failed:", err)
+				return newAPIError(http.StatusBadGateway, "Refund failed at payment gateway")
+			}
+			refund, err = recordGatewayRefund(ctx, tx, gr, req.Reason)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to refund payment")
+			return
+		}
+		c.JSON(http.StatusCreated, refund)
+	})
+
+	// Refunds issued against a payment
+	auth.GET("/payments/:id/refunds", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(),
+			"SELECT "+refundColumns+" FROM refunds WHERE payment_id=$1 AND user_id=$2 ORDER BY created_at", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		refunds := make([]Refund, 0)
+		for rows.Next() {
+			if r, err := scanRefund(rows); err == nil {
+				refunds = append(refunds, r)
+			}
+		}
+		c.JSON(http.StatusOK, refunds)
+	})
+}
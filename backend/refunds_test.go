@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestOrderStatusForRefunds(t *testing.T) {
+	tests := []struct {
+		paid, refunded float64
+		want           string
+	}{
+		{500, 0, orderPaid},
+		{500, -1, orderPaid},
+		{500, 200, orderPartiallyRefunded},
+		{500, 499.99, orderPartiallyRefunded},
+		{500, 500, orderRefunded},
+		{500, 500.004, orderRefunded},
+		{0.1 + 0.2, 0.3, orderRefunded},
+	}
+	for _, tt := range tests {
+		if got := orderStatusForRefunds(tt.paid, tt.refunded); got != tt.want {
+			t.Errorf("orderStatusForRefunds(%v, %v) = %q, want %q", tt.paid, tt.refunded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Rule match types
+const (
+	matchKeyword = "keyword" // pattern is a word or phrase found in the description
+	matchRegex   = "regex"   // pattern is a case-insensitive regular expression
+)
+
+// CategoryRule is a user-defined categorization rule. A rule matches when its pattern
+// matches the description and the amount falls within the optional range.
+type CategoryRule struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	MatchType string    `json:"match_type"`
+	Pattern   string    `json:"pattern"`
+	MinAmount *float64  `json:"min_amount"`
+	MaxAmount *float64  `json:"max_amount"`
+	Category  string    `json:"category"`
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+
+	re *regexp.Regexp // compiled pattern of a regex rule, set when rules are loaded
+}
+
+const categoryRuleColumns = "id, user_id, match_type, pattern, min_amount, max_amount, category, priority, created_at"
+
+func scanCategoryRule(row pgx.Row) (CategoryRule, error) {
+	var r CategoryRule
+	err := row.Scan(&r.ID, &r.UserID, &r.MatchType, &r.Pattern, &r.MinAmount, &r.MaxAmount, &r.Category, &r.Priority, &r.CreatedAt)
+	return r, err
+}
+
+// validate checks a rule before it is stored
+func (r CategoryRule) validate() error {
+	if strings.TrimSpace(r.Pattern) == "" || strings.TrimSpace(r.Category) == "" {
+		return errors.New("pattern and category required")
+	}
+	if len(r.Pattern) > 200 {
+		return errors.New("pattern too long")
+	}
+	switch r.MatchType {
+	case matchKeyword:
+	case matchRegex:
+		if _, err := r.compile(); err != nil {
+			return errors.New("invalid regex: " + err.Error())
+		}
+	default:
+		return errors.New("match_type must be keyword or regex")
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		return errors.New("min_amount cannot exceed max_amount")
+	}
+	return nil
+}
+
+// compile compiles a regex rule's pattern, case-insensitively
+func (r CategoryRule) compile() (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + r.Pattern)
+}
+
+// matches reports whether the rule applies to a description and (optional) amount
+func (r CategoryRule) matches(description string, amount *float64) bool {
+	if amount != nil {
+		if r.MinAmount != nil && *amount < *r.MinAmount {
+			return false
+		}
+		if r.MaxAmount != nil && *amount > *r.MaxAmount {
+			return false
+		}
+	} else if r.MinAmount != nil || r.MaxAmount != nil {
+		return false
+	}
+	switch r.MatchType {
+	case matchKeyword:
+		return containsPhrase(normalizeDescription(description), normalizeDescription(r.Pattern))
+	case matchRegex:
+		re := r.re
+		if re == nil {
+			// A rule that didn't come from loadCategoryRules
+			var err error
+			if re, err = r.compile(); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(description)
+	}
+	return false
+}
+
+// containsPhrase reports whether phrase occurs in text on word boundaries; both must be normalized
+func containsPhrase(text, phrase string) bool {
+	return phrase != "" && strings.Contains(" "+text+" ", " "+phrase+" ")
+}
+
+// loadCategoryRules returns a user's rules in evaluation order, with regex patterns compiled
+func loadCategoryRules(ctx context.Context, userID int) ([]CategoryRule, error) {
+	rows, err := db.Query(ctx, "SELECT "+categoryRuleColumns+" FROM category_rules WHERE user_id=$1 ORDER BY priority DESC, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []CategoryRule
+	for rows.Next() {
+		r, err := scanCategoryRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		if r.MatchType == matchRegex {
+			// Patterns were validated when saved; one that no longer compiles just never matches
+			if r.re, err = r.compile(); err != nil {
+				continue
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// categoryKeywords is the built-in dictionary, covering the categories the AI service predicts
+// (see ai-categorizer/ai_service.py). Phrases are matched on word boundaries.
+var categoryKeywords = []struct {
+	Category string
+	Keywords []string
+}{
+	{"Food", []string{"lunch", "dinner", "breakfast", "restaurant", "swiggy", "zomato", "cafe", "coffee", "pizza", "burger", "snacks", "canteen", "tea"}},
+	{"Groceries", []string{"grocery", "groceries", "vegetables", "fruits", "milk", "bigbasket", "blinkit", "zepto", "dmart", "supermarket", "kirana"}},
+	{"Transport", []string{"uber", "ola", "rapido", "taxi", "cab", "metro", "bus", "auto", "rickshaw", "parking", "toll"}},
+	{"Petrol", []string{"petrol", "diesel", "fuel", "cng"}},
+	{"Travel", []string{"flight", "hotel", "train", "irctc", "makemytrip", "goibibo", "airbnb", "trip", "holiday"}},
+	{"Entertainment", []string{"movie", "cinema", "pvr", "inox", "concert", "bookmyshow", "game", "gaming"}},
+	{"Subscriptions", []string{"netflix", "spotify", "prime", "hotstar", "youtube premium", "subscription", "membership"}},
+	{"Utilities", []string{"electricity", "water bill", "gas", "internet", "wifi", "broadband", "lpg"}},
+	{"Bills", []string{"bill", "postpaid", "maintenance", "emi"}},
+	{"Recharge", []string{"recharge", "prepaid", "dth", "top up"}},
+	{"Rent", []string{"rent", "lease", "pg"}},
+	{"Insurance", []string{"insurance", "premium", "lic", "policy"}},
+	{"Health", []string{"doctor", "pharmacy", "medicine", "medicines", "hospital", "clinic", "apollo", "lab test", "dentist", "gym"}},
+	{"Education", []string{"tuition", "course", "books", "school", "college", "fees", "udemy", "coursera", "exam"}},
+	{"Shopping", []string{"amazon", "flipkart", "meesho", "ajio", "mall", "shopping"}},
+	{"Clothing", []string{"myntra", "shirt", "tshirt", "jeans", "shoes", "dress", "clothes", "kurta", "saree"}},
+	{"Personal Care", []string{"salon", "haircut", "barber", "spa", "shampoo", "toothpaste"}},
+	{"Beauty", []string{"nykaa", "makeup", "cosmetics", "skincare", "lipstick", "parlour"}},
+	{"Home Items", []string{"furniture", "utensils", "bedsheet", "curtains", "ikea", "appliance", "mattress", "cleaning"}},
+	{"Stationary", []string{"stationery", "stationary", "pen", "pens", "notebook", "printout", "xerox"}},
+	{"Phone Accessory", []string{"phone case", "mobile cover", "screen guard", "charger", "earphones", "earbuds", "power bank"}},
+	{"Laptop and Computer Accessory", []string{"laptop", "keyboard", "mouse", "monitor", "ssd", "pendrive", "hard disk", "usb"}},
+	{"Gifts", []string{"gift", "gifts", "birthday", "anniversary"}},
+	{"Charity", []string{"donation", "charity", "ngo", "temple"}},
+	{"Pets", []string{"pet", "dog", "cat", "vet", "pedigree"}},
+	{"Kids", []string{"toys", "diapers", "daycare", "creche", "baby"}},
+	{"Investment", []string{"sip", "mutual fund", "stocks", "zerodha", "groww", "fd", "ppf"}},
+	{"Salary", []string{"salary", "payroll", "stipend"}},
+}
+
+// dictionaryCategorize matches a description against the built-in dictionary.
+// The category with the most keyword hits wins; ties go to the earlier category.
+func dictionaryCategorize(description string) (categoryPrediction, bool) {
+	text := normalizeDescription(description)
+	best, bestHits := "", 0
+	for _, entry := range categoryKeywords {
+		hits := 0
+		for _, kw := range entry.Keywords {
+			if containsPhrase(text, kw) {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = entry.Category, hits
+		}
+	}
+	if bestHits == 0 {
+		return categoryPrediction{}, false
+	}
+	return categoryPrediction{Category: best, Confidence: 0.8, Source: sourceRule}, true
+}
+
+// categorizeDescription runs the categorization pipeline: the user's own rules, then the
+// merchant mappings learned from their corrections, then the built-in dictionary, then the
+// AI service (which falls back to "Other" when unavailable). userID 0 skips the per-user steps.
+func categorizeDescription(ctx context.Context, userID int, description string, amount *float64) (categoryPrediction, error) {
+	var rules []CategoryRule
+	if userID != 0 {
+		var err error
+		if rules, err = loadCategoryRules(ctx, userID); err != nil {
+			return categoryPrediction{}, err
+		}
+	}
+	return categorizeWithRules(ctx, userID, rules, description, amount)
+}
+
+// categorizeWithRules is categorizeDescription with the user's rules already loaded
+func categorizeWithRules(ctx context.Context, userID int, rules []CategoryRule, description string, amount *float64) (categoryPrediction, error) {
+	if p, ok, err := localCategorize(ctx, userID, rules, description, amount); err != nil || ok {
+		return p, err
+	}
+	return categorizer.Categorize(ctx, description), nil
+}
+
+// localCategorize runs the steps of the pipeline that don't call the AI service
+func localCategorize(ctx context.Context, userID int, rules []CategoryRule, description string, amount *float64) (categoryPrediction, bool, error) {
+	if userID != 0 {
+		for _, r := range rules {
+			if r.matches(description, amount) {
+				return categoryPrediction{Category: r.Category, Confidence: 1, Source: sourceRule}, true, nil
+			}
+		}
+		if p, ok, err := learnedCategorize(ctx, userID, description); err != nil || ok {
+			return p, ok, err
+		}
+	}
+	p, ok := dictionaryCategorize(description)
+	return p, ok, nil
+}
+
+// categorizeExpense fills in a blank category from the categorization pipeline and records
+// where the category came from. A category sent by the client is the user's own choice.
+func categorizeExpense(ctx context.Context, userID int, exp *Expense) error {
+	err := fillExpenseCategory(ctx, userID, exp)
+	var apiErr *apiError
+	if err != nil && !errors.As(err, &apiErr) {
+		fmt.Println("[CATEGORIZER ERROR]", err)
+		return errors.New("Failed to categorize expense")
+	}
+	return err
+}
+
+func fillExpenseCategory(ctx context.Context, userID int, exp *Expense) error {
+	if strings.TrimSpace(exp.Category) != "" {
+		category, err := resolveCategory(ctx, db, userID, exp.Category)
+		exp.Category, exp.CategoryConfidence, exp.CategorySource = category, 1, sourceUser
+		return err
+	}
+	p := categoryPrediction{Category: "Other", Source: sourceFallback}
+	if strings.TrimSpace(exp.Description) != "" {
+		amount := exp.Amount
+		var err error
+		if p, err = categorizeDescription(ctx, userID, exp.Description, &amount); err != nil {
+			return err
+		}
+	}
+	p, err := resolvePrediction(ctx, userID, p)
+	exp.Category, exp.CategoryConfidence, exp.CategorySource = p.Category, p.Confidence, p.Source
+	return err
+}
+
+// resolvePrediction maps a predicted category onto the user's categories. A prediction the
+// user has no category for (say they merged it away) becomes Other.
+func resolvePrediction(ctx context.Context, userID int, p categoryPrediction) (categoryPrediction, error) {
+	category, err := resolveCategory(ctx, db, userID, p.Category)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return categoryPrediction{Category: "Other", Source: sourceFallback}, nil
+	}
+	p.Category = category
+	return p, err
+}
+
+func registerRuleRoutes(auth *gin.RouterGroup) {
+	// Confirm or correct an expense's category. Either way the category becomes the user's
+	// choice; with create_rule a keyword rule is saved so similar descriptions get it next time.
+	auth.POST("/expenses/:id/category", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Category   string `json:"category"` // empty confirms the current category
+			CreateRule bool   `json:"create_rule"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		var exp Expense
+		var rule *CategoryRule
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			exp, err = lockOwnedExpense(ctx, tx, atoi(c.Param("id")), userID)
+			if err != nil {
+				return err
+			}
+			category, err := resolveCategory(ctx, tx, userID, req.Category)
+			if err != nil {
+				return err
+			}
+			if category != "" && category != exp.Category {
+				if err := recordCategoryCorrection(ctx, tx, userID, exp, category); err != nil {
+					return err
+				}
+				exp.Category = category
+			}
+			if exp.Category == "" {
+				return newAPIError(http.StatusBadRequest, "Category required")
+			}
+			exp.CategoryConfidence, exp.CategorySource = 1, sourceUser
+			if _, err := tx.Exec(ctx, "UPDATE expenses SET category=$1, category_confidence=$2, category_source=$3 WHERE id=$4 AND user_id=$5",
+				exp.Category, exp.CategoryConfidence, exp.CategorySource, exp.ID, userID); err != nil {
+				return err
+			}
+			if !req.CreateRule {
+				return nil
+			}
+			r := CategoryRule{MatchType: matchKeyword, Pattern: normalizeDescription(exp.Description), Category: exp.Category}
+			if r.validate() != nil {
+				return newAPIError(http.StatusBadRequest, "Expense description cannot be used as a rule")
+			}
+			r, err = scanCategoryRule(tx.QueryRow(ctx,
+				"INSERT INTO category_rules (user_id, match_type, pattern, category) VALUES ($1, $2, $3, $4) RETURNING "+categoryRuleColumns,
+				userID, r.MatchType, r.Pattern, r.Category))
+			rule = &r
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update category")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"expense": exp, "rule": rule})
+	})
+
+	auth.GET("/categories/rules", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rules, err := loadCategoryRules(context.Background(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		if rules == nil {
+			rules = []CategoryRule{}
+		}
+		c.JSON(http.StatusOK, rules)
+	})
+
+	auth.POST("/categories/rules", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var rule CategoryRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if rule.MatchType == "" {
+			rule.MatchType = matchKeyword
+		}
+		if err := rule.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		category, err := resolveCategory(context.Background(), db, userID, rule.Category)
+		if err != nil {
+			respondError(c, err, "Failed to add rule")
+			return
+		}
+		rule.Category = category
+		rule, err = scanCategoryRule(db.QueryRow(context.Background(),
+			"INSERT INTO category_rules (user_id, match_type, pattern, min_amount, max_amount, category, priority) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+categoryRuleColumns,
+			userID, rule.MatchType, rule.Pattern, rule.MinAmount, rule.MaxAmount, rule.Category, rule.Priority))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add rule"})
+			return
+		}
+		c.JSON(http.StatusCreated, rule)
+	})
+
+	auth.PUT("/categories/rules/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var rule CategoryRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if rule.MatchType == "" {
+			rule.MatchType = matchKeyword
+		}
+		if err := rule.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		category, err := resolveCategory(context.Background(), db, userID, rule.Category)
+		if err != nil {
+			respondError(c, err, "Failed to update rule")
+			return
+		}
+		rule.Category = category
+		rule, err = scanCategoryRule(db.QueryRow(context.Background(),
+			"UPDATE category_rules SET match_type=$1, pattern=$2, min_amount=$3, max_amount=$4, category=$5, priority=$6 WHERE id=$7 AND user_id=$8 RETURNING "+categoryRuleColumns,
+			rule.MatchType, rule.Pattern, rule.MinAmount, rule.MaxAmount, rule.Category, rule.Priority, atoi(c.Param("id")), userID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule"})
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	})
+
+	auth.DELETE("/categories/rules/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "DELETE FROM category_rules WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rule"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	})
+
+	// Try a description (and optional amount) against the caller's rules without saving anything
+	auth.POST("/categories/rules/test", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Description string   `json:"description"`
+			Amount      *float64 `json:"amount"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Description == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Description required"})
+			return
+		}
+		rules, err := loadCategoryRules(context.Background(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		for _, r := range rules {
+			if r.matches(req.Description, req.Amount) {
+				c.JSON(http.StatusOK, gin.H{"matched": true, "rule": r})
+				return
+			}
+		}
+		if p, ok := dictionaryCategorize(req.Description); ok {
+			c.JSON(http.StatusOK, gin.H{"matched": true, "dictionary": p.Category})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"matched": false})
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCategoryRuleValidate(t *testing.T) {
+	lo, hi := 100.0, 500.0
+	tests := []struct {
+		name    string
+		rule    CategoryRule
+		wantErr bool
+	}{
+		{"keyword", CategoryRule{MatchType: matchKeyword, Pattern: "uber", Category: "Transport"}, false},
+		{"regex", CategoryRule{MatchType: matchRegex, Pattern: `^swiggy\b`, Category: "Food"}, false},
+		{"bad regex", CategoryRule{MatchType: matchRegex, Pattern: "(unclosed", Category: "Food"}, true},
+		{"missing pattern", CategoryRule{MatchType: matchKeyword, Pattern: " ", Category: "Food"}, true},
+		{"missing category", CategoryRule{MatchType: matchKeyword, Pattern: "uber"}, true},
+		{"unknown match type", CategoryRule{MatchType: "glob", Pattern: "uber*", Category: "Transport"}, true},
+		{"amount range", CategoryRule{MatchType: matchKeyword, Pattern: "uber", Category: "Transport", MinAmount: &lo, MaxAmount: &hi}, false},
+		{"inverted amount range", CategoryRule{MatchType: matchKeyword, Pattern: "uber", Category: "Transport", MinAmount: &hi, MaxAmount: &lo}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCategoryRuleMatches(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+	keyword := CategoryRule{MatchType: matchKeyword, Pattern: "Big Basket"}
+	regex := CategoryRule{MatchType: matchRegex, Pattern: `^amzn\s+mktp`}
+	bounded := CategoryRule{MatchType: matchKeyword, Pattern: "uber", MinAmount: amount(100), MaxAmount: amount(500)}
+	tests := []struct {
+		name        string
+		rule        CategoryRule
+		description string
+		amount      *float64
+		want        bool
+	}{
+		{"keyword phrase", keyword, "Order at big-basket.com", nil, true},
+		{"keyword needs whole words", keyword, "bigbasket order", nil, false},
+		{"regex is case-insensitive", regex, "AMZN Mktp IN*123", nil, true},
+		{"regex anchored", regex, "refund amzn mktp", nil, false},
+		{"bad regex never matches", CategoryRule{MatchType: matchRegex, Pattern: "("}, "(", nil, false},
+		{"within bounds", bounded, "Uber trip", amount(250), true},
+		{"bounds are inclusive", bounded, "Uber trip", amount(500), true},
+		{"below minimum", bounded, "Uber trip", amount(99.99), false},
+		{"above maximum", bounded, "Uber trip", amount(501), false},
+		{"bounded rule needs an amount", bounded, "Uber trip", nil, false},
+		{"unbounded rule ignores amount", keyword, "big basket", amount(10), true},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.matches(tt.description, tt.amount); got != tt.want {
+			t.Errorf("%s: matches(%q) = %v, want %v", tt.name, tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestLocalCategorizeRuleOrder(t *testing.T) {
+	// Rules arrive from loadCategoryRules highest priority first; the first match wins
+	rules := []CategoryRule{
+		{MatchType: matchRegex, Pattern: `uber.*airport`, Category: "Travel", Priority: 10},
+		{MatchType: matchKeyword, Pattern: "uber", Category: "Commute", Priority: 0},
+	}
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"Uber to airport", "Travel"},
+		{"Uber to office", "Commute"},
+	}
+	for _, tt := range tests {
+		p, ok, err := localCategorize(context.Background(), 1, rules, tt.description, nil)
+		if err != nil || !ok || p.Category != tt.want || p.Source != sourceRule || p.Confidence != 1 {
+			t.Errorf("localCategorize(%q) = %+v, %v, %v; want %s from a rule", tt.description, p, ok, err, tt.want)
+		}
+	}
+}
+
+func TestDictionaryCategorize(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+		wantOK      bool
+	}{
+		{"Swiggy dinner", "Food", true},
+		{"PIZZA", "Food", true},
+		{"Paid electricity bill", "Utilities", true},
+		{"uber to the flight", "Transport", true}, // one hit each; the earlier category wins
+		{"hotel and flight via uber", "Travel", true},
+		{"petrol pump", "Petrol", true},
+		{"pgp keys", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		p, ok := dictionaryCategorize(tt.description)
+		if ok != tt.wantOK || p.Category != tt.want {
+			t.Errorf("dictionaryCategorize(%q) = %q, %v; want %q, %v", tt.description, p.Category, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Settlement statuses. A settlement paid online stays pending until its gateway order is paid.
+const (
+	settlementPending   = "pending"
+	settlementCompleted = "completed"
+	settlementCancelled = "cancelled"
+)
+
+// Settlement is a transfer from one person to another that pays back what they owe for shared
+// expenses, in a group or between the two of them. It counts once its Payment row exists.
+type Settlement struct {
+	ID         int       `json:"id"`
+	GroupID    *int      `json:"group_id"`
+	FromUserID int       `json:"from_user_id"`
+	FromName   string    `json:"from_name"`
+	ToUserID   int       `json:"to_user_id"`
+	ToName     string    `json:"to_name"`
+	Amount     float64   `json:"amount"`
+	Status     string    `json:"status"`
+	PaymentID  *int      `json:"payment_id"` // the debtor's payment, once made
+	OrderID    *string   `json:"order_id"`   // latest gateway order, when paid online
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const settlementColumns = "st.id, st.group_id, st.from_user_id, fu.name, st.to_user_id, tu.name, st.amount, st.status, " +
+	"(SELECT p.id FROM payments p WHERE p.settlement_id=st.id ORDER BY p.id LIMIT 1), " +
+	"(SELECT o.order_id FROM payment_orders o WHERE o.settlement_id=st.id ORDER BY o.id DESC LIMIT 1), st.created_at " +
+	"FROM settlements st JOIN users fu ON fu.id=st.from_user_id JOIN users tu ON tu.id=st.to_user_id"
+
+func scanSettlement(row pgx.Row) (Settlement, error) {
+	var s Settlement
+	err := row.Scan(&s.ID, &s.GroupID, &s.FromUserID, &s.FromName, &s.ToUserID, &s.ToName, &s.Amount, &s.Status, &s.PaymentID, &s.OrderID, &s.CreatedAt)
+	return s, err
+}
+
+// completeSettlement makes pay the debtor's payment for a settlement and tells the other side.
+// Money that arrives for a cancelled settlement still completes it.
+func completeSettlement(ctx context.Context, tx pgx.Tx, id int, pay *Payment) error {
+	s, err := scanSettlement(tx.QueryRow(ctx, "SELECT "+settlementColumns+" WHERE st.id=$1 FOR UPDATE OF st", id))
+	if err != nil {
+		return err
+	}
+	var recordedBy int
+	if err := tx.QueryRow(ctx, "SELECT created_by FROM settlements WHERE id=$1", id).Scan(&recordedBy); err != nil {
+		return err
+	}
+	description := "Settle-up with " + s.ToName
+	if _, err := tx.Exec(ctx, "UPDATE payments SET settlement_id=$1, description=$2 WHERE id=$3", id, description, pay.ID); err != nil {
+		return err
+	}
+	pay.SettlementID, pay.Description = &id, &description
+	if _, err := tx.Exec(ctx, "UPDATE settlements SET status=$1, completed_at=now() WHERE id=$2", settlementCompleted, id); err != nil {
+		return err
+	}
+	// Whoever didn't record the settlement hears about it
+	if recordedBy == s.ToUserID {
+		return notify(ctx, tx, s.FromUserID, "settlement", fmt.Sprintf("%s recorded your payment of ₹%.2f to settle up.", s.ToName, pay.Amount), "settlement", id)
+	}
+	return notify(ctx, tx, s.ToUserID, "settlement", fmt.Sprintf("%s paid you ₹%.2f to settle up.", s.FromName, pay.Amount), "settlement", id)
+}
+
+// scopeFromQuery reads the optional group_id that selects a group's balances instead of the
+// user's balances outside groups. The user must belong to the group.
+func scopeFromQuery(ctx context.Context, q querier, userID int, groupParam string) (balanceScope, []GroupMember, error) {
+	scope := balanceScope{UserID: userID}
+	if groupParam == "" {
+		return scope, nil, nil
+	}
+	groupID, err := strconv.Atoi(groupParam)
+	if err != nil {
+		return scope, nil, newAPIError(http.StatusBadRequest, "Invalid group_id")
+	}
+	if _, err := groupRole(ctx, q, groupID, userID); err != nil {
+		return scope, nil, err
+	}
+	g, err := loadGroup(ctx, q, groupID)
+	if err != nil {
+		return scope, nil, err
+	}
+	scope.GroupID = &groupID
+	return scope, g.Members, nil
+}
+
+// sharedExpenseWhere selects expenses the user paid for others or has a share in
+const sharedExpenseWhere = "(user_id=$1 AND split_method IS NOT NULL) OR EXISTS (SELECT 1 FROM expense_splits sp WHERE sp.expense_id=expenses.id AND sp.user_id=$1)"
+
+// sharedExpenses loads the expenses matching where, with their split method and splits
+func sharedExpenses(ctx context.Context, where string, args ...any) ([]gin.H, error) {
+	rows, err := db.Query(ctx,
+		"SELECT "+expenseColumns+", COALESCE(split_method, '') FROM expenses WHERE "+where+" ORDER BY date DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	var expenses []Expense
+	var methods []string
+	for rows.Next() {
+		var e Expense
+		var method string
+		err := rows.Scan(&e.ID, &e.UserID, &e.Date, &e.Category, &e.Amount, &e.PaymentStatus, &e.Description, &e.Paid,
+			&e.CategoryConfidence, &e.CategorySource, &e.AccountID, &e.GroupID, &e.PaidAmount, &e.Tags, &method)
+		if err == nil {
+			expenses = append(expenses, e)
+			methods = append(methods, method)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(expenses))
+	for i, e := range expenses {
+		ids[i] = e.ID
+	}
+	splits, err := loadSplits(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]gin.H, 0, len(expenses))
+	for i, e := range expenses {
+		parts := splits[e.ID]
+		if parts == nil {
+			parts = []ExpenseSplit{}
+		}
+		result = append(result, gin.H{"expense": e, "split_method": methods[i], "splits": parts})
+	}
+	return result, nil
+}
+
+func registerSettleUpRoutes(auth *gin.RouterGroup) {
+	// An expense's participants and what each owes; visible to the payer and the participants
+	auth.GET("/expenses/:id/participants", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		expenses, err := sharedExpenses(context.Background(), "id=$2 AND (user_id=$1 OR "+sharedExpenseWhere+")", userID, atoi(c.Param("id")))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		if len(expenses) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusOK, expenses[0])
+	})
+
+	// Set who shares an expense and how much each owes; only the payer can. Participants are
+	// SmartBill users given by user_id or email, and must be members for a group expense. An
+	// empty list stops sharing an expense outside a group.
+	auth.PUT("/expenses/:id/participants", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			SplitMethod string       `json:"split_method"`
+			Splits      []splitInput `json:"splits"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := context.Background()
+		expenseID := atoi(c.Param("id"))
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			exp, err := lockOwnedExpense(ctx, tx, expenseID, userID)
+			if err != nil {
+				return err
+			}
+			if err := resolveParticipants(ctx, tx, input.Splits); err != nil {
+				return err
+			}
+			groupName := ""
+			if exp.GroupID != nil {
+				g, err := loadGroup(ctx, tx, *exp.GroupID)
+				if err != nil {
+					return err
+				}
+				groupName = g.Name
+				input.SplitMethod, input.Splits = defaultSplit(g, input.SplitMethod, input.Splits)
+				if err := checkGroupParticipants(ctx, tx, g.ID, input.Splits); err != nil {
+					return err
+				}
+			} else if len(input.Splits) == 0 {
+				if _, err := tx.Exec(ctx, "DELETE FROM expense_splits WHERE expense_id=$1", exp.ID); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "UPDATE expenses SET split_method=NULL WHERE id=$1", exp.ID)
+				return err
+			} else if input.SplitMethod == "" {
+				input.SplitMethod = "equal"
+			}
+			if err := saveSplits(ctx, tx, exp, input.SplitMethod, input.Splits); err != nil {
+				return err
+			}
+			return notifySplit(ctx, tx, exp, groupName)
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update participants")
+			return
+		}
+		expenses, err := sharedExpenses(ctx, "id=$2 AND user_id=$1", userID, expenseID)
+		if err != nil || len(expenses) == 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, expenses[0])
+	})
+
+	// Expenses the user paid for others or has a share in, newest first
+	auth.GET("/shared-expenses", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		expenses, err := sharedExpenses(context.Background(), sharedExpenseWhere, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, expenses)
+	})
+
+	// Balances, who owes whom and the transfers that clear them: in the group given by
+	// ?group_id=, simplified to as few as possible, otherwise one per person the user shares
+	// expenses with outside groups
+	auth.GET("/settle-up", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		scope, members, err := scopeFromQuery(ctx, db, userID, c.Query("group_id"))
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		balances, debts, err := shareBalances(ctx, db, scope, members)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"balances": balances, "debts": debts, "transfers": scope.transfers(balances, debts)})
+	})
+
+	// Settle up: record the user's part of the transfers that clear the scope's debts. Each transfer becomes a
+	// Payment by the person paying. With pay_online the user's own transfers are paid through
+	// gateway orders instead and are recorded when the order is paid; transfers the user
+	// receives are left for the payer. Transfers between other people are returned as remaining.
+	auth.POST("/settle-up", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var input struct {
+			GroupID   *int `json:"group_id"`
+			PayOnline bool `json:"pay_online"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.PayOnline && gateway == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payment gateway is not configured"})
+			return
+		}
+		ctx := context.Background()
+		groupParam := ""
+		if input.GroupID != nil {
+			groupParam = strconv.Itoa(*input.GroupID)
+		}
+		settlements := make([]Settlement, 0)
+		remaining := make([]debt, 0)
+		var online []Settlement
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			scope, members, err := scopeFromQuery(ctx, tx, userID, groupParam)
+			if err != nil {
+				return err
+			}
+			// Serialise settling up the same group, or the same user's balances outside groups
+			if scope.GroupID != nil {
+				_, err = tx.Exec(ctx, "SELECT 1 FROM groups WHERE id=$1 FOR UPDATE", *scope.GroupID)
+			} else {
+				_, err = tx.Exec(ctx, "SELECT 1 FROM users WHERE id=$1 FOR UPDATE", userID)
+			}
+			if err != nil {
+				return err
+			}
+			// Online payments the user started before and never finished are replaced by this plan
+			if _, err := tx.Exec(ctx,
+				"UPDATE settlements SET status=$1 WHERE from_user_id=$2 AND status=$3 AND group_id IS NOT DISTINCT FROM $4",
+				settlementCancelled, userID, settlementPending, scope.GroupID); err != nil {
+				return err
+			}
+			balances, debts, err := shareBalances(ctx, tx, scope, members)
+			if err != nil {
+				return err
+			}
+			for _, t := range scope.transfers(balances, debts) {
+				if t.FromUserID != userID && t.ToUserID != userID {
+					remaining = append(remaining, t)
+					continue
+				}
+				if input.PayOnline && t.FromUserID != userID {
+					remaining = append(remaining, t)
+					continue
+				}
+				status := settlementCompleted
+				if input.PayOnline {
+					status = settlementPending
+				}
+				var id int
+				err := tx.QueryRow(ctx,
+					"INSERT INTO settlements (group_id, from_user_id, to_user_id, amount, status, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+					scope.GroupID, t.FromUserID, t.ToUserID, t.Amount, status, userID).Scan(&id)
+				if err != nil {
+					return err
+				}
+				if !input.PayOnline {
+					pay := Payment{UserID: t.FromUserID, PaymentDate: time.Now(), Amount: t.Amount}
+					err := tx.QueryRow(ctx, "INSERT INTO payments (user_id, payment_date, amount) VALUES ($1, $2, $3) RETURNING id",
+						pay.UserID, pay.PaymentDate, pay.Amount).Scan(&pay.ID)
+					if err != nil {
+						return err
+					}
+					if err := completeSettlement(ctx, tx, id, &pay); err != nil {
+						return err
+					}
+				}
+				s, err := scanSettlement(tx.QueryRow(ctx, "SELECT "+settlementColumns+" WHERE st.id=$1", id))
+				if err != nil {
+					return err
+				}
+				if input.PayOnline {
+					online = append(online, s)
+				} else {
+					settlements = append(settlements, s)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondError(c, err, "Failed to settle up")
+			return
+		}
+
+		// Gateway orders are created once the settlements are committed, through the same flow as
+		// checkout for an expense, and in rupees like the balances they settle. If one can't be created, it and the settlements after it are
+		// cancelled, and the orders already created are returned with the error so they can
+		// still be paid.
+		orders := make([]gin.H, 0, len(online))
+		for i, s := range online {
+			order, err := createPaymentOrder(ctx, db, userID, int64(math.Round(s.Amount*100)), "INR", nil, &s.ID)
+			if err != nil {
+				for _, rest := range online[i:] {
+					if _, cancelErr := db.Exec(ctx, "UPDATE settlements SET status=$1 WHERE id=$2", settlementCancelled, rest.ID); cancelErr != nil {
+						fmt.Println("[SETTLE-UP ERROR] Failed to cancel settlement", rest.ID, ":", cancelErr)
+					}
+				}
+				status, message := http.StatusInternalServerError, "Failed to save order"
+				var apiErr *apiError
+				if errors.As(err, &apiErr) {
+					status, message = apiErr.Status, apiErr.Message
+				}
+				c.JSON(status, gin.H{"error": message, "settlements": settlements, "orders": orders, "remaining": remaining})
+				return
+			}
+			s.OrderID = &order.ID
+			settlements = append(settlements, s)
+			details := checkoutDetails(order)
+			details["settlement_id"] = s.ID
+			orders = append(orders, details)
+		}
+		c.JSON(http.StatusCreated, gin.H{"settlements": settlements, "orders": orders, "remaining": remaining})
+	})
+
+	// Settlements in the group given by ?group_id=, otherwise the user's own outside groups
+	auth.GET("/settlements", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		scope, _, err := scopeFromQuery(ctx, db, userID, c.Query("group_id"))
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		_, where, arg := scope.where()
+		rows, err := db.Query(ctx, "SELECT "+settlementColumns+" WHERE "+where+" ORDER BY st.created_at DESC, st.id DESC", arg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		settlements := make([]Settlement, 0)
+		for rows.Next() {
+			if s, err := scanSettlement(rows); err == nil {
+				settlements = append(settlements, s)
+			}
+		}
+		c.JSON(http.StatusOK, settlements)
+	})
+}
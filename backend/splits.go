@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// splitMethods are the ways an expense can be divided between its participants
+var splitMethods = map[string]bool{"equal": true, "exact": true, "percentage": true, "shares": true}
+
+// splitInput names a participant and, except in equal splits, the exact amount, percentage or
+// number of shares they take
+type splitInput struct {
+	UserID int     `json:"user_id"`
+	Email  string  `json:"email,omitempty"` // identifies the participant when user_id is not given
+	Value  float64 `json:"value"`
+}
+
+// ExpenseSplit is one participant's share of an expense
+type ExpenseSplit struct {
+	UserID int      `json:"user_id"`
+	Name   string   `json:"name"`
+	Value  *float64 `json:"value,omitempty"` // as given, for exact, percentage and shares splits
+	Amount float64  `json:"amount"`
+}
+
+// allocate divides amount in proportion to weights, in whole paise. Paise lost to rounding go to
+// the largest remainders, so the parts always add up to the amount.
+func allocate(amount float64, weights []float64) []float64 {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	paise := int64(math.Round(amount * 100))
+	parts := make([]int64, len(weights))
+	remainders := make([]float64, len(weights))
+	var given int64
+	for i, w := range weights {
+		exact := float64(paise) * w / total
+		parts[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(parts[i])
+		given += parts[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; given < paise; i++ {
+		parts[order[i%len(order)]]++
+		given++
+	}
+	amounts := make([]float64, len(parts))
+	for i, p := range parts {
+		amounts[i] = float64(p) / 100
+	}
+	return amounts
+}
+
+// computeSplits works out what each participant owes of amount under method
+func computeSplits(amount float64, method string, parts []splitInput) ([]float64, error) {
+	if !splitMethods[method] {
+		return nil, errors.New("split_method must be one of equal, exact, percentage or shares")
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("A split needs at least one participant")
+	}
+	seen := make(map[int]bool, len(parts))
+	weights := make([]float64, len(parts))
+	var total float64
+	for i, p := range parts {
+		if seen[p.UserID] {
+			return nil, errors.New("Each participant can appear only once in a split")
+		}
+		seen[p.UserID] = true
+		weights[i] = p.Value
+		if method == "equal" {
+			weights[i] = 1
+		} else if p.Value < 0 {
+			return nil, errors.New("Split values must not be negative")
+		}
+		total += weights[i]
+	}
+	switch method {
+	case "exact":
+		if roundMoney(total) != roundMoney(amount) {
+			return nil, errors.New("Exact split amounts must add up to the expense amount")
+		}
+		amounts := make([]float64, len(parts))
+		for i, w := range weights {
+			amounts[i] = roundMoney(w)
+		}
+		return amounts, nil
+	case "percentage":
+		if math.Abs(total-100) > 0.01 {
+			return nil, errors.New("Split percentages must add up to 100")
+		}
+	case "shares":
+		if total <= 0 {
+			return nil, errors.New("Split shares must add up to more than zero")
+		}
+	}
+	return allocate(amount, weights), nil
+}
+
+// scaleExactSplit rescales the amounts of an exact split so they add up to amount, keeping
+// their proportions. Parts that were all zero are shared equally.
+func scaleExactSplit(amount float64, parts []splitInput) {
+	weights := make([]float64, len(parts))
+	var total float64
+	for i, p := range parts {
+		weights[i] = p.Value
+		total += p.Value
+	}
+	if total <= 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	for i, a := range allocate(amount, weights) {
+		parts[i].Value = a
+	}
+}
+
+// saveSplits replaces an expense's split with parts divided under method
+func saveSplits(ctx context.Context, tx pgx.Tx, exp Expense, method string, parts []splitInput) error {
+	amounts, err := computeSplits(exp.Amount, method, parts)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, err.Error())
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM expense_splits WHERE expense_id=$1", exp.ID); err != nil {
+		return err
+	}
+	for i, p := range parts {
+		var value *float64
+		if method != "equal" {
+			value = &parts[i].Value
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO expense_splits (expense_id, user_id, value, amount) VALUES ($1, $2, $3, $4)",
+			exp.ID, p.UserID, value, amounts[i]); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, "UPDATE expenses SET split_method=$1 WHERE id=$2", method, exp.ID)
+	return err
+}
+
+// resplitExpense divides a split expense again after its amount changed, keeping the method
+// and participants. Exact amounts are scaled to the new amount in their existing proportions.
+func resplitExpense(ctx context.Context, tx pgx.Tx, exp Expense) error {
+	var method *string
+	if err := tx.QueryRow(ctx, "SELECT split_method FROM expenses WHERE id=$1", exp.ID).Scan(&method); err != nil || method == nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, "SELECT user_id, COALESCE(value, 0) FROM expense_splits WHERE expense_id=$1 ORDER BY user_id", exp.ID)
+	if err != nil {
+		return err
+	}
+	var parts []splitInput
+	for rows.Next() {
+		var p splitInput
+		if err := rows.Scan(&p.UserID, &p.Value); err != nil {
+			rows.Close()
+			return err
+		}
+		parts = append(parts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	if *method == "exact" {
+		scaleExactSplit(exp.Amount, parts)
+	}
+	return saveSplits(ctx, tx, exp, *method, parts)
+}
+
+// resolveParticipants fills in the user ID of participants given by email, and checks that
+// every participant is a SmartBill user
+func resolveParticipants(ctx context.Context, q querier, parts []splitInput) error {
+	for i, p := range parts {
+		var err error
+		if p.UserID == 0 && p.Email != "" {
+			err = q.QueryRow(ctx, "SELECT id FROM users WHERE lower(email)=lower($1)", strings.TrimSpace(p.Email)).Scan(&parts[i].UserID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("No SmartBill user has the email %s", p.Email))
+			}
+		} else {
+			err = q.QueryRow(ctx, "SELECT id FROM users WHERE id=$1", p.UserID).Scan(&parts[i].UserID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Unknown participant %d", p.UserID))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifySplit tells the participants of a split expense, other than the payer, their share.
+// groupName is empty for expenses shared outside a group.
+func notifySplit(ctx context.Context, q querier, exp Expense, groupName string) error {
+	var payer string
+	if err := q.QueryRow(ctx, "SELECT name FROM users WHERE id=$1", exp.UserID).Scan(&payer); err != nil {
+		return err
+	}
+	splits, err := loadSplits(ctx, q, []int{exp.ID})
+	if err != nil {
+		return err
+	}
+	for _, s := range splits[exp.ID] {
+		if s.UserID == exp.UserID || s.Amount <= 0 {
+			continue
+		}
+		kind, msg := "shared_expense", fmt.Sprintf("%s shared %q (₹%.2f) with you; your share is ₹%.2f.", payer, exp.Description, exp.Amount, s.Amount)
+		if groupName != "" {
+			kind, msg = "group_expense", fmt.Sprintf("%s added %q (₹%.2f) to %s; your share is ₹%.2f.", payer, exp.Description, exp.Amount, groupName, s.Amount)
+		}
+		if err := notify(ctx, q, s.UserID, kind, msg, "expense", exp.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSplits returns the splits of the given expenses keyed by expense ID
+func loadSplits(ctx context.Context, q querier, expenseIDs []int) (map[int][]ExpenseSplit, error) {
+	rows, err := q.Query(ctx,
+		"SELECT s.expense_id, s.user_id, u.name, s.value, s.amount FROM expense_splits s JOIN users u ON u.id=s.user_id "+
+			"WHERE s.expense_id = ANY($1) ORDER BY s.expense_id, u.name", expenseIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	splits := make(map[int][]ExpenseSplit)
+	for rows.Next() {
+		var expenseID int
+		var s ExpenseSplit
+		if err := rows.Scan(&expenseID, &s.UserID, &s.Name, &s.Value, &s.Amount); err != nil {
+			return nil, err
+		}
+		splits[expenseID] = append(splits[expenseID], s)
+	}
+	return splits, rows.Err()
+}
+
+// memberBalance is what someone has paid for shared expenses and settlements against their
+// share of the expenses and what they were paid back. A positive balance is owed to them; a
+// negative one is owed by them.
+type memberBalance struct {
+	UserID   int     `json:"user_id"`
+	Name     string  `json:"name"`
+	Paid     float64 `json:"paid"`
+	Share    float64 `json:"share"`
+	Sent     float64 `json:"sent"`     // settlements paid to others
+	Received float64 `json:"received"` // settlements received from others
+	Balance  float64 `json:"balance"`
+}
+
+// debt is money one person owes another
+type debt struct {
+	FromUserID int     `json:"from_user_id"`
+	FromName   string  `json:"from_name"`
+	ToUserID   int     `json:"to_user_id"`
+	ToName     string  `json:"to_name"`
+	Amount     float64 `json:"amount"`
+}
+
+// balanceScope selects the split expenses and settlements balances are worked out over: a
+// group's, or, outside groups, those between UserID and each person they share expenses with
+type balanceScope struct {
+	GroupID *int
+	UserID  int
+}
+
+// where returns conditions on expenses e joined to expense_splits sp, and on settlements st,
+// with their one argument
+func (s balanceScope) where() (splits, settlements string, arg int) {
+	if s.GroupID != nil {
+		return "e.group_id=$1", "st.group_id=$1", *s.GroupID
+	}
+	return "e.group_id IS NULL AND (e.user_id=$1 OR sp.user_id=$1)",
+		"st.group_id IS NULL AND (st.from_user_id=$1 OR st.to_user_id=$1)", s.UserID
+}
+
+// transfers is how the debts in a scope are settled. A group's are simplified across all its
+// members. Outside groups each person only shares expenses with the user, so each pairwise
+// debt is paid directly: pooling them would have people pay someone they share nothing with.
+func (s balanceScope) transfers(balances []memberBalance, debts []debt) []debt {
+	if s.GroupID != nil {
+		return simplifyDebts(balances)
+	}
+	return debts
+}
+
+// shareBalances works out everyone's balance in a scope, and who owes whom: every participant
+// owes the payer their share of an expense, settlements pay those debts back, and what two
+// people owe each other is netted. Members start with a zero balance even without expenses.
+func shareBalances(ctx context.Context, q querier, scope balanceScope, members []GroupMember) ([]memberBalance, []debt, error) {
+	balances := make(map[int]*memberBalance, len(members))
+	for _, m := range members {
+		balances[m.UserID] = &memberBalance{UserID: m.UserID, Name: m.Name}
+	}
+	type pair struct{ from, to int }
+	owed := make(map[pair]float64)
+	splitWhere, settlementWhere, arg := scope.where()
+	// Rows are (debtor, creditor, amount); only payments that exist count as settled
+	rows, err := q.Query(ctx,
+		"SELECT sp.user_id, su.name, e.user_id, pu.name, sp.amount, false FROM expenses e JOIN expense_splits sp ON sp.expense_id=e.id "+
+			"JOIN users pu ON pu.id=e.user_id JOIN users su ON su.id=sp.user_id WHERE "+splitWhere+
+			" UNION ALL "+
+			"SELECT st.from_user_id, fu.name, st.to_user_id, tu.name, p.amount, true FROM settlements st JOIN payments p ON p.settlement_id=st.id "+
+			"JOIN users fu ON fu.id=st.from_user_id JOIN users tu ON tu.id=st.to_user_id WHERE "+settlementWhere, arg)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var from, to int
+		var fromName, toName string
+		var amount float64
+		var settlement bool
+		if err := rows.Scan(&from, &fromName, &to, &toName, &amount, &settlement); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		// Former members still appear while they have a share in the group's expenses
+		for id, name := range map[int]string{from: fromName, to: toName} {
+			if balances[id] == nil {
+				balances[id] = &memberBalance{UserID: id, Name: name}
+			}
+		}
+		if settlement {
+			balances[from].Sent += amount
+			balances[to].Received += amount
+			owed[pair{to, from}] += amount
+			continue
+		}
+		balances[to].Paid += amount
+		balances[from].Share += amount
+		if from != to {
+			owed[pair{from, to}] += amount
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]memberBalance, 0, len(balances))
+	for _, b := range balances {
+		b.Paid, b.Share, b.Sent, b.Received = roundMoney(b.Paid), roundMoney(b.Share), roundMoney(b.Sent), roundMoney(b.Received)
+		b.Balance = roundMoney(b.Paid - b.Share + b.Sent - b.Received)
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	debts := make([]debt, 0)
+	for p, amount := range owed {
+		if net := roundMoney(amount - owed[pair{p.to, p.from}]); net > 0 {
+			debts = append(debts, debt{FromUserID: p.from, FromName: balances[p.from].Name, ToUserID: p.to, ToName: balances[p.to].Name, Amount: net})
+		}
+	}
+	sortDebts(debts)
+	return result, debts, nil
+}
+
+func sortDebts(debts []debt) {
+	sort.Slice(debts, func(i, j int) bool {
+		if debts[i].FromName != debts[j].FromName {
+			return debts[i].FromName < debts[j].FromName
+		}
+		return debts[i].ToName < debts[j].ToName
+	})
+}
+
+// simplifyDebts finds a small set of transfers that brings every balance to zero. Debtors and
+// creditors with equal and opposite balances settle directly; the rest are matched largest
+// debtor to largest creditor, which needs at most one transfer fewer than there are people.
+func simplifyDebts(balances []memberBalance) []debt {
+	type party struct {
+		userID int
+		name   string
+		paise  int64
+	}
+	var debtors, creditors []*party
+	for _, b := range balances {
+		paise := int64(math.Round(b.Balance * 100))
+		switch {
+		case paise < 0:
+			debtors = append(debtors, &party{b.UserID, b.Name, -paise})
+		case paise > 0:
+			creditors = append(creditors, &party{b.UserID, b.Name, paise})
+		}
+	}
+	transfers := make([]debt, 0)
+	pay := func(d, c *party, paise int64) {
+		transfers = append(transfers, debt{FromUserID: d.userID, FromName: d.name, ToUserID: c.userID, ToName: c.name, Amount: float64(paise) / 100})
+		d.paise -= paise
+		c.paise -= paise
+	}
+	for _, d := range debtors {
+		for _, c := range creditors {
+			if d.paise > 0 && d.paise == c.paise {
+				pay(d, c, d.paise)
+				break
+			}
+		}
+	}
+	for {
+		var d, c *party
+		for _, p := range debtors {
+			if p.paise > 0 && (d == nil || p.paise > d.paise) {
+				d = p
+			}
+		}
+		for _, p := range creditors {
+			if p.paise > 0 && (c == nil || p.paise > c.paise) {
+				c = p
+			}
+		}
+		if d == nil || c == nil {
+			break
+		}
+		pay(d, c, min(d.paise, c.paise))
+	}
+	sortDebts(transfers)
+	return transfers
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		amount  float64
+		weights []float64
+		want    string
+	}{
+		{100, []float64{1, 1, 1}, "[33.34 33.33 33.33]"},
+		{10, []float64{1, 2}, "[3.33 6.67]"},
+		{99.99, []float64{50, 50}, "[50 49.99]"},
+		{0.05, []float64{1, 1, 1, 1, 1, 1}, "[0.01 0.01 0.01 0.01 0.01 0]"},
+		{250, []float64{60, 40}, "[150 100]"},
+		{1000, []float64{0, 1}, "[0 1000]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(allocate(tt.amount, tt.weights)); got != tt.want {
+			t.Errorf("allocate(%v, %v) = %s, want %s", tt.amount, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestComputeSplits(t *testing.T) {
+	parts := func(values ...float64) []splitInput {
+		out := make([]splitInput, len(values))
+		for i, v := range values {
+			out[i] = splitInput{UserID: i + 1, Value: v}
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		amount  float64
+		method  string
+		parts   []splitInput
+		want    string
+		wantErr bool
+	}{
+		{"equal ignores values", 100, "equal", parts(5, 0, -1), "[33.34 33.33 33.33]", false},
+		{"exact", 100, "exact", parts(70.5, 29.5), "[70.5 29.5]", false},
+		{"exact must add up", 100, "exact", parts(70, 20), "", true},
+		{"percentage", 250, "percentage", parts(60, 40), "[150 100]", false},
+		{"percentage must add up", 250, "percentage", parts(60, 39), "", true},
+		{"shares", 90, "shares", parts(2, 1), "[60 30]", false},
+		{"shares must be positive", 90, "shares", parts(0, 0), "", true},
+		{"negative value", 90, "shares", parts(3, -1), "", true},
+		{"duplicate participant", 90, "equal", []splitInput{{UserID: 1}, {UserID: 1}}, "", true},
+		{"no participants", 90, "equal", nil, "", true},
+		{"unknown method", 90, "halves", parts(1, 1), "", true},
+	}
+	for _, tt := range tests {
+		got, err := computeSplits(tt.amount, tt.method, tt.parts)
+		if (err != nil) != tt.wantErr || (err == nil && fmt.Sprint(got) != tt.want) {
+			t.Errorf("%s: computeSplits = %v, %v; want %s (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestScaleExactSplit(t *testing.T) {
+	tests := []struct {
+		amount float64
+		values []float64
+		want   string
+	}{
+		{200, []float64{30, 70}, "[60 140]"},
+		{10, []float64{1, 2}, "[3.33 6.67]"},
+		{10, []float64{0, 0}, "[5 5]"},
+		{100, []float64{100}, "[100]"},
+	}
+	for _, tt := range tests {
+		parts := make([]splitInput, len(tt.values))
+		for i, v := range tt.values {
+			parts[i].Value = v
+		}
+		scaleExactSplit(tt.amount, parts)
+		got := make([]float64, len(parts))
+		for i, p := range parts {
+			got[i] = p.Value
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("scaleExactSplit(%v, %v) = %v, want %s", tt.amount, tt.values, got, tt.want)
+		}
+	}
+}
+
+// debtString renders transfers as "A->B 10" for comparison
+func debtString(debts []debt) string {
+	s := "["
+	for i, d := range debts {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%s->%s %v", d.FromName, d.ToName, d.Amount)
+	}
+	return s + "]"
+}
+
+func TestSimplifyDebts(t *testing.T) {
+	balances := func(named map[string]float64) []memberBalance {
+		out := make([]memberBalance, 0, len(named))
+		for _, name := range []string{"A", "B", "C", "D"} {
+			if v, ok := named[name]; ok {
+				out = append(out, memberBalance{UserID: int(name[0]), Name: name, Balance: v})
+			}
+		}
+		return out
+	}
+	tests := []struct {
+		name     string
+		balances []memberBalance
+		want     string
+	}{
+		{"settled", balances(map[string]float64{"A": 0, "B": 0.001}), "[]"},
+		{"one pair", balances(map[string]float64{"A": -30, "B": 30}), "[A->B 30]"},
+		{"middleman skipped", balances(map[string]float64{"A": -10, "B": 0, "C": 10}), "[A->C 10]"},
+		{"equal amounts settle directly", balances(map[string]float64{"A": -20, "B": -30, "C": 30, "D": 20}), "[A->D 20 B->C 30]"},
+		{"largest first", balances(map[string]float64{"A": -50, "B": -10, "C": 40, "D": 20}), "[A->C 40 A->D 10 B->D 10]"},
+		{"paise", balances(map[string]float64{"A": -33.33, "B": -33.34, "C": 66.67}), "[A->C 33.33 B->C 33.34]"},
+	}
+	for _, tt := range tests {
+		if got := debtString(simplifyDebts(tt.balances)); got != tt.want {
+			t.Errorf("%s: simplifyDebts = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBalanceScopeTransfers(t *testing.T) {
+	// A owes B and B owes C the same amount
+	balances := []memberBalance{{UserID: 1, Name: "A", Balance: -10}, {UserID: 2, Name: "B"}, {UserID: 3, Name: "C", Balance: 10}}
+	debts := []debt{{FromUserID: 1, FromName: "A", ToUserID: 2, ToName: "B", Amount: 10}, {FromUserID: 2, FromName: "B", ToUserID: 3, ToName: "C", Amount: 10}}
+	groupID := 7
+	tests := []struct {
+		name  string
+		scope balanceScope
+		want  string
+	}{
+		{"group debts are simplified", balanceScope{GroupID: &groupID}, "[A->C 10]"},
+		{"personal debts are paid directly", balanceScope{UserID: 2}, "[A->B 10 B->C 10]"},
+	}
+	for _, tt := range tests {
+		if got := debtString(tt.scope.transfers(balances, debts)); got != tt.want {
+			t.Errorf("%s: transfers = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// statusOpen is the status of a statement whose cycle hasn't closed yet. Closed statements
+// take their status from their payments the way expenses do.
+const statusOpen = "Open"
+
+// CardStatement is one billing cycle of a credit card account: the expenses charged to the card
+// from PeriodStart to the closing date PeriodEnd, payable by DueDate
+type CardStatement struct {
+	ID          int       `json:"id"`
+	AccountID   int       `json:"account_id"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	DueDate     time.Time `json:"due_date"`
+	Total       float64   `json:"total"`
+	PaidAmount  float64   `json:"paid_amount"` // linked payments less refunds that have not failed
+	Status      string    `json:"status"`
+}
+
+// Outstanding is the amount still owed on the statement, never negative
+func (s CardStatement) Outstanding() float64 {
+	if s.PaidAmount >= s.Total {
+		return 0
+	}
+	return roundMoney(s.Total - s.PaidAmount)
+}
+
+// MarshalJSON formats the dates as YYYY-MM-DD and adds the outstanding amount and whether it is overdue
+func (s CardStatement) MarshalJSON() ([]byte, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return json.Marshal(struct {
+		ID          int     `json:"id"`
+		AccountID   int     `json:"account_id"`
+		PeriodStart string  `json:"period_start"`
+		PeriodEnd   string  `json:"period_end"`
+		DueDate     string  `json:"due_date"`
+		Total       float64 `json:"total"`
+		PaidAmount  float64 `json:"paid_amount"`
+		Outstanding float64 `json:"outstanding"`
+		Status      string  `json:"status"`
+		Overdue     bool    `json:"overdue"`
+	}{
+		s.ID, s.AccountID, s.PeriodStart.Format("2006-01-02"), s.PeriodEnd.Format("2006-01-02"), s.DueDate.Format("2006-01-02"),
+		s.Total, s.PaidAmount, s.Outstanding(), s.Status, s.Status != statusOpen && s.Outstanding() > 0 && s.DueDate.Before(today),
+	})
+}
+
+const statementColumns = "id, account_id, period_start, period_end, due_date, total, paid_amount, status"
+
+func scanStatement(row pgx.Row) (CardStatement, error) {
+	var s CardStatement
+	err := row.Scan(&s.ID, &s.AccountID, &s.PeriodStart, &s.PeriodEnd, &s.DueDate, &s.Total, &s.PaidAmount, &s.Status)
+	return s, err
+}
+
+// lockOwnedStatement loads and row-locks a statement, failing with 404 unless it belongs to userID
+func lockOwnedStatement(ctx context.Context, tx pgx.Tx, id, userID int) (CardStatement, error) {
+	s, err := scanStatement(tx.QueryRow(ctx, "SELECT "+statementColumns+" FROM card_statements WHERE id=$1 AND user_id=$2 FOR UPDATE", id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, newAPIError(http.StatusNotFound, "Statement not found")
+	}
+	return s, err
+}
+
+// dayOfMonth is the given day of a month, clamped to the month's last day. The month may
+// overflow into the next or previous year.
+func dayOfMonth(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// statementCycle is the billing cycle containing date for a card that closes on statementDay
+// and is due on dueDay: the cycle runs from the day after the previous closing date to the next
+// one, and falls due on the first dueDay after it closes
+func statementCycle(date time.Time, statementDay, dueDay int) (start, end, due time.Time) {
+	end = dayOfMonth(date.Year(), date.Month(), statementDay)
+	if date.After(end) {
+		end = dayOfMonth(date.Year(), date.Month()+1, statementDay)
+	}
+	start = dayOfMonth(end.Year(), end.Month()-1, statementDay).AddDate(0, 0, 1)
+	due = dayOfMonth(end.Year(), end.Month(), dueDay)
+	if !due.After(end) {
+		due = dayOfMonth(end.Year(), end.Month()+1, dueDay)
+	}
+	return start, end, due
+}
+
+// reconcileStatement recomputes a statement's paid amount and status from its linked payments.
+// Like reconcileExpense it must run in the transaction that changed the payments.
+func reconcileStatement(ctx context.Context, tx pgx.Tx, id, userID int) (CardStatement, error) {
+	s, err := lockOwnedStatement(ctx, tx, id, userID)
+	if err != nil {
+		return s, err
+	}
+	err = tx.QueryRow(ctx,
+		"SELECT (SELECT COALESCE(SUM(p.amount), 0) FROM payments p WHERE p.statement_id=$1) - "+
+			"(SELECT COALESCE(SUM(rf.amount), 0) FROM refunds rf JOIN payments p ON p.id=rf.payment_id WHERE p.statement_id=$1 AND rf.status<>'failed')",
+		id).Scan(&s.PaidAmount)
+	if err != nil {
+		return s, err
+	}
+	s.PaidAmount = roundMoney(s.PaidAmount)
+	previous := s.Status
+	now := time.Now()
+	switch {
+	case !s.PeriodEnd.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)):
+		s.Status = statusOpen
+	case roundMoney(s.Total) <= 0 && s.PaidAmount <= 0:
+		s.Status = statusPaid // nothing was charged
+	default:
+		s.Status = paymentStatusFor(s.Total, s.PaidAmount)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE card_statements SET paid_amount=$1, status=$2 WHERE id=$3", s.PaidAmount, s.Status, id); err != nil {
+		return s, err
+	}
+	// The bill is payable once the cycle closes
+	if previous == statusOpen && s.Status != statusOpen && s.Outstanding() > 0 {
+		var name string
+		if err := tx.QueryRow(ctx, "SELECT name FROM accounts WHERE id=$1", s.AccountID).Scan(&name); err != nil {
+			return s, err
+		}
+		msg := fmt.Sprintf("Your %s statement of ₹%.2f is due on %s.", name, s.Outstanding(), s.DueDate.Format("2 Jan 2006"))
+		if err := notify(ctx, tx, userID, "statement", msg, "statement", s.ID); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// syncStatements brings a credit card's statements in line with the expenses charged to it:
+// every cycle with expenses, and the current one, gets a statement with an up-to-date total.
+// Statements that no longer match a cycle are removed unless payments were made against them.
+func syncStatements(ctx context.Context, tx pgx.Tx, userID int, a Account) error {
+	if a.Type != "credit_card" || a.StatementDay == nil || a.DueDay == nil {
+		return nil
+	}
+	// Serialise syncs of the same card
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM accounts WHERE id=$1 FOR UPDATE", a.ID); err != nil {
+		return err
+	}
+	type cycle struct {
+		start, end, due time.Time
+		total           float64
+	}
+	cycles := make(map[time.Time]*cycle)
+	addTo := func(date time.Time, amount float64) {
+		start, end, due := statementCycle(date, *a.StatementDay, *a.DueDay)
+		c := cycles[end]
+		if c == nil {
+			c = &cycle{start: start, end: end, due: due}
+			cycles[end] = c
+		}
+		c.total += amount
+	}
+	rows, err := tx.Query(ctx, "SELECT date, amount FROM expenses WHERE account_id=$1 AND user_id=$2", a.ID, userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var date time.Time
+		var amount float64
+		if err := rows.Scan(&date, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		addTo(date, amount)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	addTo(time.Now(), 0)
+
+	ends := make([]time.Time, 0, len(cycles))
+	for end := range cycles {
+		ends = append(ends, end)
+	}
+	sort.Slice(ends, func(i, j int) bool { return ends[i].Before(ends[j]) })
+	var ids []int
+	for _, end := range ends {
+		c := cycles[end]
+		var id int
+		err := tx.QueryRow(ctx,
+			"INSERT INTO card_statements (user_id, account_id, period_start, period_end, due_date, total) VALUES ($1, $2, $3, $4, $5, $6) "+
+				"ON CONFLICT (account_id, period_end) DO UPDATE SET period_start=EXCLUDED.period_start, due_date=EXCLUDED.due_date, total=EXCLUDED.total RETURNING id",
+			userID, a.ID, c.start, c.end, c.due, roundMoney(c.total)).Scan(&id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	// Cycles that no longer exist, after the card's statement day changed or expenses moved
+	if _, err := tx.Exec(ctx,
+		"DELETE FROM card_statements s WHERE s.account_id=$1 AND NOT (s.period_end = ANY($2)) AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.statement_id=s.id)",
+		a.ID, ends); err != nil {
+		return err
+	}
+	rows, err = tx.Query(ctx, "UPDATE card_statements SET total=0 WHERE account_id=$1 AND NOT (period_end = ANY($2)) RETURNING id", a.ID, ends)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := reconcileStatement(ctx, tx, id, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncUserStatements syncs the statements of all of a user's credit cards
+func syncUserStatements(ctx context.Context, userID int) error {
+	rows, err := db.Query(ctx,
+		"SELECT "+accountColumns+" FROM accounts WHERE user_id=$1 AND type='credit_card' AND statement_day IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+	var cards []Account
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		cards = append(cards, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, a := range cards {
+		if err := withTx(ctx, func(tx pgx.Tx) error { return syncStatements(ctx, tx, userID, a) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listStatements lists a user's statements, newest first
+func listStatements(ctx context.Context, userID int, where string, args ...any) ([]CardStatement, error) {
+	rows, err := db.Query(ctx,
+		"SELECT "+statementColumns+" FROM card_statements WHERE user_id=$1"+where+" ORDER BY period_end DESC, id DESC", append([]any{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	statements := make([]CardStatement, 0)
+	for rows.Next() {
+		if s, err := scanStatement(rows); err == nil {
+			statements = append(statements, s)
+		}
+	}
+	return statements, rows.Err()
+}
+
+func registerStatementRoutes(auth *gin.RouterGroup) {
+	// Statements of all credit cards; ?unpaid=true lists only those with an amount outstanding
+	auth.GET("/statements", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		if err := syncUserStatements(ctx, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update statements"})
+			return
+		}
+		where := ""
+		if c.Query("unpaid") == "true" {
+			where = " AND paid_amount < total"
+		}
+		statements, err := listStatements(ctx, userID, where)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, statements)
+	})
+
+	auth.GET("/accounts/:id/statements", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		a, err := loadOwnedAccount(ctx, db, atoi(c.Param("id")), userID)
+		if err != nil {
+			respondError(c, err, "DB error")
+			return
+		}
+		if a.Type != "credit_card" || a.StatementDay == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Account is not a credit card with a statement cycle"})
+			return
+		}
+		if err := withTx(ctx, func(tx pgx.Tx) error { return syncStatements(ctx, tx, userID, a) }); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update statements"})
+			return
+		}
+		statements, err := listStatements(ctx, userID, " AND account_id=$2", a.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		c.JSON(http.StatusOK, statements)
+	})
+
+	// A statement with the expenses charged in its cycle and the payments made against it
+	auth.GET("/statements/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		ctx := context.Background()
+		s, err := scanStatement(db.QueryRow(ctx, "SELECT "+statementColumns+" FROM card_statements WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Statement not found"})
+			return
+		}
+		a, err := loadOwnedAccount(ctx, db, s.AccountID, userID)
+		if err == nil {
+			err = withTx(ctx, func(tx pgx.Tx) error { return syncStatements(ctx, tx, userID, a) })
+		}
+		if err == nil {
+			s, err = scanStatement(db.QueryRow(ctx, "SELECT "+statementColumns+" FROM card_statements WHERE id=$1", s.ID))
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Statement not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update statements"})
+			return
+		}
+		rows, err := db.Query(ctx,
+			"SELECT "+expenseColumns+" FROM expenses WHERE account_id=$1 AND user_id=$2 AND date BETWEEN $3 AND $4 ORDER BY date, id",
+			s.AccountID, userID, s.PeriodStart, s.PeriodEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		expenses := make([]Expense, 0)
+		for rows.Next() {
+			if exp, err := scanExpense(rows); err == nil {
+				expenses = append(expenses, exp)
+			}
+		}
+		rows.Close()
+		rows, err = db.Query(ctx,
+			"SELECT id, user_id, payment_date, amount, expense_id, category, description, account_id, statement_id FROM payments WHERE statement_id=$1 ORDER BY payment_date, id", s.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		payments := make([]Payment, 0)
+		for rows.Next() {
+			var pay Payment
+			if err := rows.Scan(&pay.ID, &pay.UserID, &pay.PaymentDate, &pay.Amount, &pay.ExpenseID, &pay.Category, &pay.Description, &pay.AccountID, &pay.StatementID); err == nil {
+				payments = append(payments, pay)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"statement": s, "expenses": expenses, "payments": payments})
+	})
+}
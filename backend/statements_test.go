@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayOfMonth(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		day   int
+		want  string
+	}{
+		{2026, time.October, 15, "2026-10-15"},
+		{2026, time.February, 31, "2026-02-28"},
+		{2028, time.February, 30, "2028-02-29"},
+		{2026, 13, 31, "2027-01-31"},
+		{2026, 0, 31, "2025-12-31"},
+	}
+	for _, tt := range tests {
+		if got := dayOfMonth(tt.year, tt.month, tt.day).Format("2006-01-02"); got != tt.want {
+			t.Errorf("dayOfMonth(%d, %d, %d) = %s, want %s", tt.year, tt.month, tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestStatementCycle(t *testing.T) {
+	tests := []struct {
+		date                 string
+		statementDay, dueDay int
+		start, end, due      string
+	}{
+		{"2026-10-10", 15, 5, "2026-09-16", "2026-10-15", "2026-11-05"},
+		{"2026-10-15", 15, 5, "2026-09-16", "2026-10-15", "2026-11-05"},
+		{"2026-10-16", 15, 5, "2026-10-16", "2026-11-15", "2026-12-05"},
+		{"2026-10-03", 5, 25, "2026-09-06", "2026-10-05", "2026-10-25"},
+		{"2026-12-20", 15, 25, "2026-12-16", "2027-01-15", "2027-01-25"},
+		{"2026-02-10", 31, 20, "2026-02-01", "2026-02-28", "2026-03-20"},
+		{"2026-03-01", 31, 20, "2026-03-01", "2026-03-31", "2026-04-20"},
+		{"2026-10-10", 15, 15, "2026-09-16", "2026-10-15", "2026-11-15"},
+	}
+	for _, tt := range tests {
+		date, _ := time.Parse("2006-01-02", tt.date)
+		start, end, due := statementCycle(date, tt.statementDay, tt.dueDay)
+		got := [3]string{start.Format("2006-01-02"), end.Format("2006-01-02"), due.Format("2006-01-02")}
+		if want := [3]string{tt.start, tt.end, tt.due}; got != want {
+			t.Errorf("statementCycle(%s, %d, %d) = %v, want %v", tt.date, tt.statementDay, tt.dueDay, got, want)
+		}
+	}
+}
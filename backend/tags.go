@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Tag is a free-form label such as "trip-goa" or "reimbursable". Expenses and payments
+// can carry any number of tags.
+type Tag struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Color        string    `json:"color"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpenseCount int       `json:"expense_count"`
+	PaymentCount int       `json:"payment_count"`
+}
+
+// tagLinks describes a join table between tags and the rows they label
+type tagLinks struct {
+	table  string // join table
+	column string // join table column referencing the labelled row
+	owner  string // table of the labelled rows, which carries user_id
+}
+
+var (
+	expenseTagLinks = tagLinks{table: "expense_tags", column: "expense_id", owner: "expenses"}
+	paymentTagLinks = tagLinks{table: "payment_tags", column: "payment_id", owner: "payments"}
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// normalizeTag lowercases a tag and joins words with hyphens: "Trip Goa" is "trip-goa"
+func normalizeTag(name string) (string, error) {
+	tag := strings.Join(strings.Fields(strings.ToLower(name)), "-")
+	if len(tag) > 50 {
+		return "", errors.New("tag too long")
+	}
+	if !tagPattern.MatchString(tag) {
+		return "", errors.New("tags may only contain letters, digits, '-' and '_'")
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes, de-duplicates and sorts tag names. The result is never nil.
+func normalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool)
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// nonNilTags makes sure tags serialize as [] rather than null
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// hasAllTags is an SQL condition that holds when the row idExpr carries every tag in the
+// text[] parameter param. An empty array matches every row.
+func hasAllTags(l tagLinks, idExpr, param string) string {
+	return "(cardinality(" + param + "::text[]) = 0 OR (SELECT COUNT(*) FROM " + l.table + " x JOIN tags t ON t.id=x.tag_id " +
+		"WHERE x." + l.column + "=" + idExpr + " AND t.name = ANY(" + param + "::text[])) = cardinality(" + param + "::text[]))"
+}
+
+// setTags replaces the tags on a row, creating tags the user doesn't have yet, and returns them
+func setTags(ctx context.Context, q querier, userID int, l tagLinks, id int, names []string) ([]string, error) {
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, err.Error())
+	}
+	if _, err := q.Exec(ctx, "DELETE FROM "+l.table+" WHERE "+l.column+"=$1", id); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return tags, nil
+	}
+	if _, err := q.Exec(ctx,
+		"INSERT INTO tags (user_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (user_id, name) DO NOTHING", userID, tags); err != nil {
+		return nil, err
+	}
+	_, err = q.Exec(ctx,
+		"INSERT INTO "+l.table+" ("+l.column+", tag_id) SELECT $1, id FROM tags WHERE user_id=$2 AND name = ANY($3::text[])", id, userID, tags)
+	return tags, err
+}
+
+func registerTagRoutes(auth *gin.RouterGroup) {
+	// The user's tags with how many expenses and payments carry each
+	auth.GET("/tags", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		rows, err := db.Query(context.Background(),
+			"SELECT t.id, t.name, t.color, t.created_at, "+
+				"(SELECT COUNT(*) FROM expense_tags et WHERE et.tag_id=t.id), (SELECT COUNT(*) FROM payment_tags pt WHERE pt.tag_id=t.id) "+
+				"FROM tags t WHERE t.user_id=$1 ORDER BY t.name", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+			return
+		}
+		defer rows.Close()
+		tags := make([]Tag, 0)
+		for rows.Next() {
+			var t Tag
+			if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt, &t.ExpenseCount, &t.PaymentCount); err == nil {
+				tags = append(tags, t)
+			}
+		}
+		c.JSON(http.StatusOK, tags)
+	})
+
+	auth.POST("/tags", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		name, err := normalizeTag(req.Name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateCategoryFields(req.Color, ""); err != nil {
+			respondError(c, err, "Invalid input")
+			return
+		}
+		t := Tag{Name: name, Color: req.Color}
+		err = db.QueryRow(context.Background(),
+			"INSERT INTO tags (user_id, name, color) VALUES ($1, $2, $3) RETURNING id, created_at", userID, name, req.Color).Scan(&t.ID, &t.CreatedAt)
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tag"})
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+	})
+
+	// Rename or recolour a tag
+	auth.PUT("/tags/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		name, err := normalizeTag(req.Name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateCategoryFields(req.Color, ""); err != nil {
+			respondError(c, err, "Invalid input")
+			return
+		}
+		t := Tag{ID: atoi(c.Param("id")), Name: name, Color: req.Color}
+		err = db.QueryRow(context.Background(),
+			"UPDATE tags SET name=$1, color=$2 WHERE id=$3 AND user_id=$4 RETURNING created_at", name, req.Color, t.ID, userID).Scan(&t.CreatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	})
+
+	// Delete a tag, removing it from every expense and payment
+	auth.DELETE("/tags/:id", func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		res, err := db.Exec(context.Background(), "DELETE FROM tags WHERE id=$1 AND user_id=$2", atoi(c.Param("id")), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+			return
+		}
+		if res.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	})
+
+	auth.PUT("/expenses/:id/tags", replaceTagsHandler(expenseTagLinks, "Expense not found"))
+	auth.PUT("/payments/:id/tags", replaceTagsHandler(paymentTagLinks, "Payment not found"))
+}
+
+// replaceTagsHandler replaces the tags on one of the caller's expenses or payments
+func replaceTagsHandler(l tagLinks, notFound string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIDFromToken(c)
+		var req struct {
+			Tags []string `json:"tags"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		id := atoi(c.Param("id"))
+		var tags []string
+		ctx := context.Background()
+		err := withTx(ctx, func(tx pgx.Tx) error {
+			var owned bool
+			err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+l.owner+" WHERE id=$1 AND user_id=$2)", id, userID).Scan(&owned)
+			if err != nil {
+				return err
+			}
+			if !owned {
+				return newAPIError(http.StatusNotFound, notFound)
+			}
+			tags, err = setTags(ctx, tx, userID, l, id, req.Tags)
+			return err
+		})
+		if err != nil {
+			respondError(c, err, "Failed to update tags")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "tags": tags})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"Trip Goa", "trip-goa", false},
+		{"  work  ", "work", false},
+		{"q4_2026", "q4_2026", false},
+		{"Tax-Deductible", "tax-deductible", false},
+		{"", "", true},
+		{"-leading", "", true},
+		{"café", "", true},
+		{"a/b", "", true},
+		{strings.Repeat("a", 50), strings.Repeat("a", 50), false},
+		{strings.Repeat("a", 51), "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeTag(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeTag(%q) = (%q, %v), want %q (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := normalizeTags([]string{"Work", "trip goa", "work", "Trip  Goa"})
+	if err != nil || strings.Join(got, ",") != "trip-goa,work" {
+		t.Errorf("normalizeTags = %v, %v", got, err)
+	}
+	if got, err := normalizeTags(nil); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("normalizeTags(nil) = %#v, %v; want empty non-nil", got, err)
+	}
+}
@@ -8,6 +8,7 @@ export default function BudgetSettings({ budget, setBudget }) {
   const [editing, setEditing] = useState(false);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
+  const [warnings, setWarnings] = useState([]);
 
   // Fetch budget from backend on mount
   useEffect(() => {
@@ -35,6 +36,25 @@ export default function BudgetSettings({ budget, setBudget }) {
     // eslint-disable-next-line
   }, []);
 
+  // Warn when this month's spending forecast exceeds the budget
+  useEffect(() => {
+    async function fetchForecast() {
+      try {
+        const token = localStorage.getItem('token');
+        const res = await fetch(`${API_URL}/api/analytics/forecast?months=0`, {
+          headers: { 'Authorization': token ? `Bearer ${token}` : undefined }
+        });
+        if (!res.ok) return;
+        const data = await res.json();
+        setWarnings(data.warnings || []);
+      } catch {
+        setWarnings([]);
+      }
+    }
+    fetchForecast();
+    // eslint-disable-next-line
+  }, [budget]);
+
   async function handleSave() {
     setLoading(true);
     setError(null);
@@ -95,6 +115,7 @@ export default function BudgetSettings({ budget, setBudget }) {
       </div>
       {loading && <div className="text-xs text-gray-400 mt-1">Loading...</div>}
       {error && <div className="text-xs text-red-500 mt-1">{error}</div>}
+      {warnings.map(w => <div key={w} className="text-xs text-orange-600 mt-1">{w}</div>)}
     </div>
   );
 }
@@ -1,7 +1,7 @@
 import { useState, useRef, useEffect } from 'react';
 
 export default function ChatbotWidget() {
-  const API_URL = import.meta.env.VITE_API_URL;
+  const BACKEND_URL = import.meta.env.VITE_BACKEND_URL;
   // Quick reply options
   const quickReplies = [
     "Show my spending this month",
@@ -49,11 +49,15 @@ export default function ChatbotWidget() {
     const controller = new AbortController();
     const timeout = setTimeout(() => controller.abort(), 10000); // 10s timeout
     try {
-  const res = await fetch(`${API_URL}/api/chatbot`, {
+  const token = localStorage.getItem('token');
+  const res = await fetch(`${BACKEND_URL}/api/chatbot`, {
   // const res = await fetch(`/api/chatbot`, {
 
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': token ? `Bearer ${token}` : undefined
+        },
         body: JSON.stringify({ message: input }),
         signal: controller.signal
       });
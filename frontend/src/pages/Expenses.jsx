@@ -171,7 +171,10 @@ function Expenses() {
             // const res = await fetch(`/api/ai/categorize`, {
 
               method: 'POST',
-              headers: { 'Content-Type': 'application/json' },
+              headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${localStorage.getItem('token')}`
+              },
               body: JSON.stringify({ description: value })
             });
             if (res.ok) {
@@ -255,7 +255,10 @@ export default function Payments() {
             // const res = await fetch(`/api/ai/categorize`, {
 
               method: 'POST',
-              headers: { 'Content-Type': 'application/json' },
+              headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${localStorage.getItem('token')}`
+              },
               body: JSON.stringify({ description: value })
             });
             if (res.ok) {
@@ -355,7 +358,7 @@ export default function Payments() {
     }
     const order = await res.json();
     const options = {
-      key: 'rzp_test_R5ZEYHFFHdm7dx',
+      key: order.key_id,
       amount: order.amount,
       currency: order.currency,
       order_id: order.id,
@@ -363,8 +366,8 @@ export default function Payments() {
       description: 'Payment',
       handler: function (response) {
         toast.success('Payment successful! Payment ID: ' + response.razorpay_payment_id);
-  // fetch(`${BACKEND_URL}/api/payments`, {
-  fetch(`/api/payments`, {
+  fetch(`${BACKEND_URL}/api/razorpay/verify`, {
+  // fetch(`/api/razorpay/verify`, {
 
           method: 'POST',
           headers: {
@@ -372,9 +375,9 @@ export default function Payments() {
             'Authorization': token ? `Bearer ${token}` : undefined
           },
           body: JSON.stringify({
-            payment_date: new Date().toISOString().slice(0, 10),
-            amount: order.amount / 100,
-            razorpay_payment_id: response.razorpay_payment_id
+            razorpay_order_id: response.razorpay_order_id,
+            razorpay_payment_id: response.razorpay_payment_id,
+            razorpay_signature: response.razorpay_signature
           })
         })
           .then(res => {
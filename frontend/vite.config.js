@@ -32,7 +32,6 @@ export default defineConfig({
   ],
   server: {
     proxy: {
-      '/api/chatbot': 'http://localhost:8000',
       '/api': 'http://localhost:8080'
     }
   }